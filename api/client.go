@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/jorenkoyen/conter/version"
 )
@@ -63,7 +64,10 @@ func (c *Client) do(ctx context.Context, method, path string, reqBody any, respD
 		reader = bytes.NewReader(raw)
 	}
 
+	// the path can contain a query string
+	path, query, _ := strings.Cut(path, "?")
 	endpoint := c.base.JoinPath(path)
+	endpoint.RawQuery = query
 	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), reader)
 	if err != nil {
 		return err
@@ -114,6 +118,79 @@ func (c *Client) CertificateInspect(ctx context.Context, domain string) (*Certif
 	return &cert, nil
 }
 
+// CertificateImport will import the user-provided certificate into the system.
+func (c *Client) CertificateImport(ctx context.Context, cmd CertificateImportCommand) (*Certificate, error) {
+	var cert Certificate
+	if err := c.do(ctx, http.MethodPost, "/api/certificates", cmd, &cert); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// CertificateOrders will return the certificate orders known in the system.
+func (c *Client) CertificateOrders(ctx context.Context) ([]CertificateOrder, error) {
+	var orders []CertificateOrder
+	if err := c.do(ctx, http.MethodGet, "/api/certificates/orders", nil, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// CertificateExport will return the certificate chain and private key for the given domain.
+// The format is either 'pem' or 'pkcs12', the password is only used to protect PKCS#12 archives.
+func (c *Client) CertificateExport(ctx context.Context, domain string, format string, password string) ([]byte, error) {
+	endpoint := c.base.JoinPath("/api/certificates", domain, "export")
+	query := url.Values{}
+	query.Set("format", format)
+	endpoint.RawQuery = query.Encode()
+
+	header := http.Header{}
+	if password != "" {
+		// the password is not sent in the query, it would end up in the access logs
+		header.Set("X-Export-Password", password)
+	}
+	return c.download(ctx, endpoint, header)
+}
+
+// CertificateAuthority will return the PEM encoded root certificate of the internal certificate authority.
+func (c *Client) CertificateAuthority(ctx context.Context) ([]byte, error) {
+	return c.download(ctx, c.base.JoinPath("/api/certificates/ca"), nil)
+}
+
+// download will retrieve the raw response body of the endpoint, the headers are added to the request.
+func (c *Client) download(ctx context.Context, endpoint *url.URL, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	output, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkResponseError(res, output); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// CertificateRevoke will revoke the certificate for the given domain and remove it from the system.
+func (c *Client) CertificateRevoke(ctx context.Context, domain string) error {
+	return c.do(ctx, http.MethodDelete, "/api/certificates/"+domain, nil, nil)
+}
+
 // CertificateRenew will renew an existing certificate for the given domain.
 func (c *Client) CertificateRenew(ctx context.Context, domain string) error {
 	endpoint := fmt.Sprintf("/api/certificates/%s/renew", domain)
@@ -152,7 +229,51 @@ func (c *Client) ProjectRemove(ctx context.Context, name string) error {
 	return c.do(ctx, http.MethodDelete, "/api/projects/"+name, nil, nil)
 }
 
+// AcmeAccount will return the ACME account registered with the issuer, an empty issuer selects the preferred issuer.
+func (c *Client) AcmeAccount(ctx context.Context, issuer string) (*AcmeAccount, error) {
+	var account AcmeAccount
+	if err := c.do(ctx, http.MethodGet, acmeAccountPath(issuer), nil, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// AcmeAccountUpdate will apply the changes to the ACME account registered with the issuer.
+func (c *Client) AcmeAccountUpdate(ctx context.Context, issuer string, cmd AcmeAccountUpdateCommand) (*AcmeAccount, error) {
+	var account AcmeAccount
+	if err := c.do(ctx, http.MethodPut, acmeAccountPath(issuer), cmd, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// acmeAccountPath will return the path of the ACME account endpoint for the issuer.
+func acmeAccountPath(issuer string) string {
+	if issuer == "" {
+		return "/api/acme/account"
+	}
+	return "/api/acme/account?issuer=" + url.QueryEscape(issuer)
+}
+
 func (c *Client) ExecuteSystemTask(ctx context.Context, task Task) error {
 	endpoint := fmt.Sprintf("/api/system/%s", string(task))
 	return c.do(ctx, http.MethodGet, endpoint, nil, nil)
 }
+
+// SystemJobs will return the status of the maintenance jobs scheduled by the daemon.
+func (c *Client) SystemJobs(ctx context.Context) ([]Job, error) {
+	var jobs []Job
+	if err := c.do(ctx, http.MethodGet, "/api/system/jobs", nil, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// SystemRotateKey will re-encrypt all private keys stored by the daemon with a new data key.
+func (c *Client) SystemRotateKey(ctx context.Context) (*EncryptionKey, error) {
+	var key EncryptionKey
+	if err := c.do(ctx, http.MethodPost, "/api/system/rotate-key", nil, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -33,6 +33,8 @@ type Certificate struct {
 	Challenge types.ChallengeType `json:"challenge"`
 	Domains   []string            `json:"domains"`
 	PEM       string              `json:"pem,omitempty"`
+	KeyType   types.KeyType       `json:"key_type,omitempty"`
+	ReuseKey  bool                `json:"reuse_key"`
 	Meta      struct {
 		Subject            string    `json:"subject"`
 		Issuer             string    `json:"issuer"`
@@ -44,6 +46,24 @@ type Certificate struct {
 	} `json:"meta,omitempty"`
 }
 
+type CertificateImportCommand struct {
+	Certificate string   `json:"certificate"`
+	Key         string   `json:"key"`
+	Domains     []string `json:"domains,omitempty"`
+}
+
+type CertificateOrder struct {
+	Domains     []string            `json:"domains"`
+	Challenge   types.ChallengeType `json:"challenge"`
+	State       types.OrderState    `json:"state"`
+	Attempts    int                 `json:"attempts"`
+	RateLimited bool                `json:"rate_limited"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	Error       string              `json:"error,omitempty"`
+	NextRetry   time.Time           `json:"next_retry"`
+}
+
 type ProjectSummary struct {
 	Name     string   `json:"name"`
 	Running  bool     `json:"running"`
@@ -56,17 +76,46 @@ type Project struct {
 }
 
 type Service struct {
-	Name    string   `json:"name"`
-	Hash    string   `json:"hash"`
-	Status  string   `json:"status"`
-	Volumes []string `json:"volumes"`
+	Name     string   `json:"name"`
+	Hash     string   `json:"hash"`
+	Status   string   `json:"status"`
+	Replicas int      `json:"replicas"`
+	Volumes  []string `json:"volumes"`
+
+	HealthCheck *types.ContainerHealthCheck `json:"healthcheck,omitempty"`
+	Containers  []ContainerStatus           `json:"containers,omitempty"`
+
 	Ingress struct {
-		Domains          []string            `json:"domains"`
-		InternalEndpoint string              `json:"internal"`
-		ChallengeType    types.ChallengeType `json:"challenge"`
+		Domains           []string            `json:"domains"`
+		Path              string              `json:"path"`
+		StripPrefix       bool                `json:"strip_prefix"`
+		InternalEndpoints []string            `json:"endpoints"`
+		ChallengeType     types.ChallengeType `json:"challenge"`
+		LoadBalancer      types.LoadBalancer  `json:"load_balancer"`
+		Health            []EndpointHealth    `json:"health,omitempty"`
 	} `json:"ingress,omitempty"`
 }
 
+type ContainerStatus struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	State        string    `json:"state"`
+	Health       string    `json:"health,omitempty"`
+	ImageDigest  string    `json:"image_digest"`
+	StartedAt    time.Time `json:"started_at"`
+	Uptime       int       `json:"uptime"` // seconds
+	RestartCount int       `json:"restart_count"`
+	ExitCode     int       `json:"exit_code"`
+	OOMKilled    bool      `json:"oom_killed"`
+}
+
+type EndpointHealth struct {
+	Endpoint  string    `json:"endpoint"`
+	Status    string    `json:"status"`
+	LastCheck time.Time `json:"last_check"`
+	Error     string    `json:"error,omitempty"`
+}
+
 type ProjectApplyCommand struct {
 	ProjectName string `json:"project_name"`
 	Services    []struct {
@@ -74,15 +123,58 @@ type ProjectApplyCommand struct {
 		Source        types.Source        `json:"source"`
 		Environment   map[string]string   `json:"environment"`
 		IngressDomain []string            `json:"ingress_domains"`
+		IngressPath   string              `json:"ingress_path"`
+		StripPrefix   bool                `json:"strip_prefix"`
+		HealthCheck   *types.HealthCheck  `json:"ingress_health_check,omitempty"`
 		ContainerPort int                 `json:"container_port"`
 		ChallengeType types.ChallengeType `json:"challenge_type"`
+		KeyType       types.KeyType       `json:"key_type,omitempty"`
+		ReuseKey      bool                `json:"reuse_key,omitempty"`
 		Quota         types.Quota         `json:"quota"`
 		Volumes       []types.Volume      `json:"volumes"`
+		Replicas      int                 `json:"replicas"`
+		LoadBalancer  types.LoadBalancer  `json:"load_balancer"`
+
+		ContainerHealthCheck *types.ContainerHealthCheck `json:"healthcheck,omitempty"`
 	} `json:"services"`
 }
 
+type Job struct {
+	Name         string    `json:"name"`
+	Interval     string    `json:"interval"`
+	Jitter       string    `json:"jitter"`
+	Result       string    `json:"result"`
+	Runs         int       `json:"runs"`
+	LastRun      time.Time `json:"last_run"`
+	LastDuration string    `json:"last_duration"`
+	NextRun      time.Time `json:"next_run"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type AcmeAccount struct {
+	Issuer        string   `json:"issuer"`
+	Directory     string   `json:"directory"`
+	Email         string   `json:"email"`
+	URI           string   `json:"uri"`
+	Status        string   `json:"status"`
+	Contact       []string `json:"contact"`
+	KeyThumbprint string   `json:"key_thumbprint"`
+}
+
+type AcmeAccountUpdateCommand struct {
+	Email       string `json:"email,omitempty"`
+	RolloverKey bool   `json:"rollover_key,omitempty"`
+	Deactivate  bool   `json:"deactivate,omitempty"`
+}
+
+type EncryptionKey struct {
+	KeyID string `json:"key_id"`
+}
+
 type Task string
 
 const (
 	TaskCertificateBatch Task = "batch_certificates"
+	TaskChallengeCleanup Task = "cleanup_challenges"
+	TaskOrderRetry       Task = "retry_orders"
 )
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jorenkoyen/conter/api"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+)
+
+func acme() *cli.Command {
+	issuerFlag := &cli.StringFlag{
+		Name:  "issuer",
+		Usage: "The name of the ACME issuer, defaults to the preferred issuer",
+	}
+
+	return &cli.Command{
+		Name:  "acme",
+		Usage: "Manage the ACME account",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "show",
+				Usage:  "Show the ACME account registered with the issuer",
+				Action: showAcmeAccountHandler,
+				Flags:  []cli.Flag{issuerFlag},
+			},
+			{
+				Name:      "update-email",
+				Usage:     "Change the contact email address of the ACME account",
+				Action:    updateAcmeEmailHandler,
+				Args:      true,
+				ArgsUsage: "[email]",
+				Flags:     []cli.Flag{issuerFlag},
+			},
+			{
+				Name:   "rollover-key",
+				Usage:  "Replace the key of the ACME account with a new key",
+				Action: rolloverAcmeKeyHandler,
+				Flags:  []cli.Flag{issuerFlag},
+			},
+			{
+				Name:   "deactivate",
+				Usage:  "Deactivate the ACME account, a new account is registered when the daemon restarts",
+				Action: deactivateAcmeAccountHandler,
+				Flags: []cli.Flag{
+					issuerFlag,
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Confirm the deactivation, this can not be undone",
+					},
+				},
+			},
+		},
+	}
+}
+
+func showAcmeAccountHandler(c *cli.Context) error {
+	client, err := clientFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	account, err := client.AcmeAccount(c.Context, c.String("issuer"))
+	if err != nil {
+		return fmt.Errorf("failed to retrieve ACME account: %w", err)
+	}
+
+	return writeAcmeAccount(account)
+}
+
+func updateAcmeEmailHandler(c *cli.Context) error {
+	email := c.Args().First()
+	if email == "" {
+		return errors.New("email argument is required")
+	}
+
+	return updateAcmeAccount(c, api.AcmeAccountUpdateCommand{Email: email})
+}
+
+func rolloverAcmeKeyHandler(c *cli.Context) error {
+	return updateAcmeAccount(c, api.AcmeAccountUpdateCommand{RolloverKey: true})
+}
+
+func deactivateAcmeAccountHandler(c *cli.Context) error {
+	if !c.Bool("yes") {
+		return errors.New("deactivating the ACME account can not be undone, use --yes to confirm")
+	}
+
+	return updateAcmeAccount(c, api.AcmeAccountUpdateCommand{Deactivate: true})
+}
+
+// updateAcmeAccount will apply the changes to the ACME account and print the result.
+func updateAcmeAccount(c *cli.Context, cmd api.AcmeAccountUpdateCommand) error {
+	client, err := clientFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	account, err := client.AcmeAccountUpdate(c.Context, c.String("issuer"), cmd)
+	if err != nil {
+		return fmt.Errorf("failed to update ACME account: %w", err)
+	}
+
+	return writeAcmeAccount(account)
+}
+
+// writeAcmeAccount will print the information of the ACME account.
+func writeAcmeAccount(account *api.AcmeAccount) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "%s:\t%s\n", "Issuer", account.Issuer)
+	fmt.Fprintf(writer, "%s:\t%s\n", "Directory", account.Directory)
+	fmt.Fprintf(writer, "%s:\t%s\n", "Email", account.Email)
+	fmt.Fprintf(writer, "%s:\t%s\n", "Status", account.Status)
+	fmt.Fprintf(writer, "%s:\t%s\n", "URI", account.URI)
+	fmt.Fprintf(writer, "%s:\t%s\n", "Contact", strings.Join(account.Contact, ","))
+	fmt.Fprintf(writer, "%s:\t%s\n", "Key Thumbprint", account.KeyThumbprint)
+	return writer.Flush()
+}
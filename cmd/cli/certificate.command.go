@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jorenkoyen/conter/api"
+	"github.com/jorenkoyen/conter/manager/types"
 	"os"
 	"strings"
 	"text/tabwriter"
@@ -38,6 +39,75 @@ func certificate() *cli.Command {
 				Args:      true,
 				ArgsUsage: "[domain]",
 			},
+			{
+				Name:   "import",
+				Usage:  "Import a certificate issued outside of ACME",
+				Action: importCertificateHandler,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "cert",
+						Usage:    "The PEM encoded certificate chain",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "key",
+						Usage:    "The PEM encoded private key",
+						Required: true,
+					},
+					&cli.StringSliceFlag{
+						Name:  "domain",
+						Usage: "The domains to use the certificate for, defaults to the names in the certificate",
+					},
+				},
+			},
+			{
+				Name:      "export",
+				Usage:     "Export the certificate chain and private key",
+				Action:    exportCertificateHandler,
+				Args:      true,
+				ArgsUsage: "[domain]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "The export format, either 'pem' or 'pkcs12'",
+						Value: "pem",
+					},
+					&cli.StringFlag{
+						Name:    "password",
+						Usage:   "The password protecting the PKCS#12 archive",
+						EnvVars: []string{"CONTER_EXPORT_PASSWORD"}, // keeps the password out of the shell history
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "The file to write the export to, defaults to stdout",
+					},
+				},
+			},
+			{
+				Name:      "revoke",
+				Usage:     "Revoke the certificate for the specified domain",
+				Action:    revokeCertificateHandler,
+				Args:      true,
+				ArgsUsage: "[domain]",
+			},
+			{
+				Name:   "ca",
+				Usage:  "Export the root certificate of the internal certificate authority",
+				Action: certificateAuthorityHandler,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "The file to write the root certificate to, defaults to stdout",
+					},
+				},
+			},
+			{
+				Name:   "orders",
+				Usage:  "List certificate orders and their progress",
+				Action: listCertificateOrdersHandler,
+			},
 			{
 				Name:   "batch",
 				Usage:  "Run the certificate management batch command",
@@ -60,7 +130,7 @@ func listCertificateHandler(c *cli.Context) error {
 
 	// write certificates output
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"DOMAIN", "CHALLENGE", "EXPIRY", "ISSUER"})
+	table.SetHeader([]string{"DOMAIN", "CHALLENGE", "KEY", "EXPIRY", "ISSUER"})
 	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
 	table.SetHeaderLine(false)
@@ -72,6 +142,7 @@ func listCertificateHandler(c *cli.Context) error {
 		data := []string{
 			strings.Join(cert.Domains, ","),
 			string(cert.Challenge),
+			keyType(cert),
 			cert.Meta.Expiry.Format(time.RFC1123),
 			cert.Meta.Issuer,
 		}
@@ -81,6 +152,7 @@ func listCertificateHandler(c *cli.Context) error {
 			table.Rich(data, []tablewriter.Colors{
 				{}, // domain
 				{}, // challenge
+				{}, // key
 				{tablewriter.Bold, tablewriter.FgHiRedColor}, // expiry
 				{}, // issuer
 			})
@@ -93,6 +165,169 @@ func listCertificateHandler(c *cli.Context) error {
 	return nil
 }
 
+// keyType will return the key type of the certificate, marking keys that are reused on renewal.
+func keyType(cert api.Certificate) string {
+	if cert.KeyType == "" {
+		return "-"
+	}
+
+	if cert.ReuseKey {
+		return string(cert.KeyType) + " (reused)"
+	}
+
+	return string(cert.KeyType)
+}
+
+func importCertificateHandler(c *cli.Context) error {
+	certificate, err := os.ReadFile(c.String("cert"))
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	key, err := os.ReadFile(c.String("key"))
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	client, err := clientFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	cert, err := client.CertificateImport(c.Context, api.CertificateImportCommand{
+		Certificate: string(certificate),
+		Key:         string(key),
+		Domains:     c.StringSlice("domain"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import certificate: %w", err)
+	}
+
+	fmt.Fprintf(os.Stdout, "Imported certificate for %s (expiry=%s)\n", strings.Join(cert.Domains, ","), cert.Meta.Expiry.Format(time.RFC1123))
+	return nil
+}
+
+func exportCertificateHandler(c *cli.Context) error {
+	domain := c.Args().First()
+	if domain == "" {
+		return errors.New("domain argument is required")
+	}
+
+	client, err := clientFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	content, err := client.CertificateExport(c.Context, domain, c.String("format"), c.String("password"))
+	if err != nil {
+		return fmt.Errorf("failed to export certificate: %w", err)
+	}
+
+	if output := c.String("output"); output != "" {
+		return os.WriteFile(output, content, 0600)
+	}
+
+	_, err = os.Stdout.Write(content)
+	return err
+}
+
+func revokeCertificateHandler(c *cli.Context) error {
+	domain := c.Args().First()
+	if domain == "" {
+		return errors.New("domain argument is required")
+	}
+
+	client, err := clientFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	err = client.CertificateRevoke(c.Context, domain)
+	if err != nil {
+		return fmt.Errorf("failed to revoke certificate: %w", err)
+	}
+
+	fmt.Fprintf(os.Stdout, "Certificate for %s has been revoked\n", domain)
+	return nil
+}
+
+func certificateAuthorityHandler(c *cli.Context) error {
+	client, err := clientFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	content, err := client.CertificateAuthority(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve certificate authority: %w", err)
+	}
+
+	if output := c.String("output"); output != "" {
+		return os.WriteFile(output, content, 0644)
+	}
+
+	_, err = os.Stdout.Write(content)
+	return err
+}
+
+func listCertificateOrdersHandler(c *cli.Context) error {
+	client, err := clientFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	orders, err := client.CertificateOrders(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to list certificate orders: %w", err)
+	}
+
+	// write orders output
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"DOMAIN", "CHALLENGE", "STATE", "ATTEMPTS", "NEXT RETRY", "ERROR"})
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetNoWhiteSpace(true)
+	table.SetTablePadding("    ")
+	table.SetAutoWrapText(false)
+
+	for _, order := range orders {
+		retry := "-"
+		if !order.NextRetry.IsZero() {
+			retry = order.NextRetry.Local().Format(time.RFC1123)
+			if order.RateLimited {
+				retry += " (rate limited)"
+			}
+		}
+
+		data := []string{
+			strings.Join(order.Domains, ","),
+			string(order.Challenge),
+			string(order.State),
+			fmt.Sprintf("%d", order.Attempts),
+			retry,
+			order.Error,
+		}
+
+		if order.State == types.OrderStateFailed {
+			table.Rich(data, []tablewriter.Colors{
+				{}, // domain
+				{}, // challenge
+				{tablewriter.Bold, tablewriter.FgHiRedColor}, // state
+				{}, // attempts
+				{}, // next retry
+				{}, // error
+			})
+		} else {
+			table.Append(data)
+		}
+	}
+
+	table.Render()
+	return nil
+}
+
 func renewCertificateHandler(c *cli.Context) error {
 	domain := c.Args().First()
 	if domain == "" {
@@ -25,6 +25,14 @@ func main() {
 			// [conterctl] project rm :name
 			// [conterctl] project inspect :name
 			project(),
+			// [conterctl] acme show
+			// [conterctl] acme update-email :email
+			// [conterctl] acme rollover-key
+			// [conterctl] acme deactivate --yes
+			acme(),
+			// [conterctl] system jobs
+			// [conterctl] system run :job
+			system(),
 		},
 	}
 
@@ -10,6 +10,7 @@ import (
 	"os"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 func project() *cli.Command {
@@ -117,10 +118,16 @@ func applyProjectHandler(c *cli.Context) error {
 		fmt.Fprintf(writer, "  %s:\n", s.Name)
 		fmt.Fprintf(writer, "    %s:\t%s\n", "Status", s.Status)
 		fmt.Fprintf(writer, "    %s:\t%s\n", "Hash", s.Hash)
+		fmt.Fprintf(writer, "    %s:\t%d\n", "Replicas", s.Replicas)
+
+		if s.HealthCheck != nil {
+			fmt.Fprintf(writer, "    %s:\t%s\n", "Health Check", s.HealthCheck.String())
+		}
 
 		if len(s.Ingress.Domains) > 0 {
 			fmt.Fprintf(writer, "    %s:\t%s\n", "Domains", strings.Join(s.Ingress.Domains, ","))
-			fmt.Fprintf(writer, "    %s:\t%s\n", "Endpoint", s.Ingress.InternalEndpoint)
+			fmt.Fprintf(writer, "    %s:\t%s (strip=%t)\n", "Path", s.Ingress.Path, s.Ingress.StripPrefix)
+			fmt.Fprintf(writer, "    %s:\t%s\n", "Endpoints", strings.Join(s.Ingress.InternalEndpoints, ","))
 			fmt.Fprintf(writer, "    %s:\t%s\n", "Challenge", string(s.Ingress.ChallengeType))
 		}
 
@@ -180,11 +187,32 @@ func inspectProjectHandler(c *cli.Context) error {
 		fmt.Fprintf(writer, "  %s:\n", s.Name)
 		fmt.Fprintf(writer, "    %s:\t%s\n", "Status", s.Status)
 		fmt.Fprintf(writer, "    %s:\t%s\n", "Hash", s.Hash)
+		fmt.Fprintf(writer, "    %s:\t%d\n", "Replicas", s.Replicas)
+
+		if s.HealthCheck != nil {
+			fmt.Fprintf(writer, "    %s:\t%s\n", "Health Check", s.HealthCheck.String())
+		}
+
+		if len(s.Containers) > 0 {
+			fmt.Fprintf(writer, "    %s:\n", "Containers")
+			for _, container := range s.Containers {
+				fmt.Fprintf(writer, "      %s:\t%s\n", container.Name, containerSummary(container))
+			}
+		}
 
 		if len(s.Ingress.Domains) > 0 {
 			fmt.Fprintf(writer, "    %s:\t%s\n", "Domains", strings.Join(s.Ingress.Domains, ","))
-			fmt.Fprintf(writer, "    %s:\t%s\n", "Endpoint", s.Ingress.InternalEndpoint)
+			fmt.Fprintf(writer, "    %s:\t%s (strip=%t)\n", "Path", s.Ingress.Path, s.Ingress.StripPrefix)
+			fmt.Fprintf(writer, "    %s:\t%s\n", "Endpoints", strings.Join(s.Ingress.InternalEndpoints, ","))
 			fmt.Fprintf(writer, "    %s:\t%s\n", "Challenge", string(s.Ingress.ChallengeType))
+
+			for _, health := range s.Ingress.Health {
+				if health.Error != "" {
+					fmt.Fprintf(writer, "    %s:\t%s [ %s ] (%s)\n", "Health", health.Endpoint, health.Status, health.Error)
+				} else {
+					fmt.Fprintf(writer, "    %s:\t%s [ %s ]\n", "Health", health.Endpoint, health.Status)
+				}
+			}
 		}
 
 		if len(s.Volumes) > 0 {
@@ -196,3 +224,37 @@ func inspectProjectHandler(c *cli.Context) error {
 
 	return nil
 }
+
+// containerSummary will return a single line describing the runtime status of the container.
+func containerSummary(c api.ContainerStatus) string {
+	state := c.State
+	if c.Health != "" {
+		state += "/" + c.Health
+	}
+
+	details := []string{
+		"id=" + shortID(c.ID),
+		"image=" + shortID(strings.TrimPrefix(c.ImageDigest, "sha256:")),
+	}
+
+	if c.Uptime > 0 {
+		details = append(details, "uptime="+(time.Duration(c.Uptime)*time.Second).String())
+	} else if !c.StartedAt.IsZero() {
+		details = append(details, "started="+c.StartedAt.Local().Format(time.RFC1123))
+	}
+
+	details = append(details, fmt.Sprintf("restarts=%d", c.RestartCount), fmt.Sprintf("exit_code=%d", c.ExitCode))
+	if c.OOMKilled {
+		details = append(details, "oom_killed")
+	}
+
+	return fmt.Sprintf("%s [ %s ]", state, strings.Join(details, ", "))
+}
+
+// shortID will return the first 12 characters of the ID, as shown by docker.
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
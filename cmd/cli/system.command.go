@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jorenkoyen/conter/api"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+func system() *cli.Command {
+	return &cli.Command{
+		Name:  "system",
+		Usage: "Manage the Conter daemon",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "jobs",
+				Usage:  "List the scheduled maintenance jobs",
+				Action: listJobsHandler,
+			},
+			{
+				Name:      "run",
+				Usage:     "Run the maintenance job immediately",
+				Action:    runJobHandler,
+				Args:      true,
+				ArgsUsage: "[job]",
+			},
+			{
+				Name:   "rotate-key",
+				Usage:  "Re-encrypt all private keys with a new data encryption key",
+				Action: rotateKeyHandler,
+			},
+		},
+	}
+}
+
+func listJobsHandler(c *cli.Context) error {
+	client, err := clientFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := client.SystemJobs(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	// write jobs output
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"JOB", "INTERVAL", "LAST RUN", "NEXT RUN", "RESULT"})
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetNoWhiteSpace(true)
+	table.SetTablePadding("    ")
+
+	for _, job := range jobs {
+		result := job.Result
+		if job.Error != "" {
+			result = fmt.Sprintf("%s (%s)", job.Result, job.Error)
+		}
+
+		data := []string{
+			job.Name,
+			job.Interval,
+			formatJobTime(job.LastRun),
+			formatJobTime(job.NextRun),
+			result,
+		}
+
+		if job.Result == "failed" {
+			table.Rich(data, []tablewriter.Colors{
+				{}, // job
+				{}, // interval
+				{}, // last run
+				{}, // next run
+				{tablewriter.Bold, tablewriter.FgHiRedColor}, // result
+			})
+		} else {
+			table.Append(data)
+		}
+	}
+
+	table.Render()
+	return nil
+}
+
+func runJobHandler(c *cli.Context) error {
+	job := c.Args().First()
+	if job == "" {
+		return errors.New("job argument is required")
+	}
+
+	client, err := clientFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	err = client.ExecuteSystemTask(c.Context, api.Task(job))
+	if err != nil {
+		return fmt.Errorf("failed to run job: %w", err)
+	}
+
+	fmt.Fprintf(os.Stdout, "OK\n")
+	return nil
+}
+
+func rotateKeyHandler(c *cli.Context) error {
+	client, err := clientFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	key, err := client.SystemRotateKey(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to rotate encryption key: %w", err)
+	}
+
+	fmt.Fprintf(os.Stdout, "All private keys are encrypted with key %s\n", key.KeyID)
+	return nil
+}
+
+// formatJobTime will format the time of a job run, a zero time indicates the job has not run (or is not planned).
+func formatJobTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format(time.RFC1123)
+}
@@ -78,17 +78,54 @@ func run(ctx context.Context, args []string) error {
 	database := db.NewClient(config.Data.Directory)
 	defer database.Close()
 
+	// enable encryption of private keys before migrating existing entries
+	key, err := config.EncryptionKey()
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		if err = database.EnableEncryption(key); err != nil {
+			return fmt.Errorf("failed to enable encryption: %w", err)
+		}
+	}
+
+	if err = database.Migrate(); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
 	// create docker client
 	dckr := docker.NewClient()
 	defer dckr.Close()
 
 	// create certificate manager
-	certificateManager := manager.NewCertificateManger(database, config.Acme.Email, config.Acme.DirectoryUrl, config.Acme.Insecure)
+	certificateManager := manager.NewCertificateManger(database, config.Acme.Email, config.AcmeIssuers(), config.Acme.Insecure)
+	certificateManager.KeyType = config.Acme.KeyType
+	certificateManager.ReuseKey = config.Acme.ReuseKey
+	if config.Acme.DNS.Provider != "" {
+		err = certificateManager.ConfigureDNS(manager.DNSOptions{
+			Provider:                config.Acme.DNS.Provider,
+			Credentials:             config.Acme.DNS.Credentials,
+			Resolvers:               config.Acme.DNS.Resolvers,
+			DisablePropagationCheck: config.Acme.DNS.DisablePropagationCheck,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to configure DNS provider: %w", err)
+		}
+	}
+
+	// create notification manager
+	notificationManager := manager.NewNotificationManager(database)
+	notificationManager.Interval = config.Notifications.Interval
+	for _, notifier := range config.Notifiers() {
+		notificationManager.Register(notifier)
+	}
+	certificateManager.Notifications = notificationManager
 
 	// create ingress manager
 	ingressManager := manager.NewIngressManager()
 	ingressManager.Database = database
 	ingressManager.CertificateManager = certificateManager
+	database.OnRoutesChanged(ingressManager.Rebuild)
 
 	// create orchestrator
 	containerManager := manager.NewContainerManager()
@@ -96,10 +133,53 @@ func run(ctx context.Context, args []string) error {
 	containerManager.Docker = dckr
 	containerManager.IngressManager = ingressManager
 
+	// create upstream health checker
+	healthChecker := proxy.NewHealthChecker()
+	healthChecker.IngressManager = ingressManager
+	go healthChecker.Start(ctx)
+
+	// create OCSP stapler
+	ocspStapler := proxy.NewOCSPStapler()
+	ocspStapler.CertificateManager = certificateManager
+	database.OnCertificatesChanged(func() {
+		go func() { _ = ocspStapler.Refresh() }()
+	})
+
 	// create proxy
 	rp := proxy.NewServer()
 	rp.IngressManager = ingressManager
 	rp.CertificateManager = certificateManager
+	rp.HealthChecker = healthChecker
+	rp.OCSPStapler = ocspStapler
+	database.OnCertificatesChanged(rp.InvalidateCertificates)
+	database.OnRoutesChanged(rp.PruneProxies)
+
+	if od := config.Proxy.OnDemand; od.Enabled {
+		rp.OnDemand = proxy.NewOnDemandIssuer()
+		rp.OnDemand.AllowedDomains = od.AllowedDomains
+		rp.OnDemand.AskURL = od.AskURL
+		rp.OnDemand.ChallengeType = od.Challenge
+		rp.OnDemand.Interval = od.Interval
+		rp.OnDemand.RequireRoute = od.RequireRoute
+		rp.OnDemand.IngressManager = ingressManager
+		rp.OnDemand.CertificateManager = certificateManager
+	}
+
+	// create scheduler for maintenance jobs
+	scheduler := manager.NewScheduler()
+	scheduler.Register(manager.JobCertificateBatch, config.Scheduler.BatchCertificates.Options(), func(ctx context.Context) error {
+		return certificateManager.Batch()
+	})
+	scheduler.Register(manager.JobChallengeCleanup, config.Scheduler.CleanupChallenges.Options(), func(ctx context.Context) error {
+		return certificateManager.CleanupChallenges(manager.StaleChallengeAge)
+	})
+	scheduler.Register(manager.JobOrderRetry, config.Scheduler.RetryOrders.Options(), func(ctx context.Context) error {
+		return certificateManager.RetryOrders()
+	})
+	scheduler.Register(manager.JobOCSPRefresh, config.Scheduler.RefreshOCSP.Options(), func(ctx context.Context) error {
+		return ocspStapler.Refresh()
+	})
+	go scheduler.Start(ctx)
 
 	// start HTTP proxy
 	go func() {
@@ -121,6 +201,8 @@ func run(ctx context.Context, args []string) error {
 	srv := server.NewServer(config.ListenAddress)
 	srv.ContainerManager = containerManager
 	srv.CertificateManager = certificateManager
+	srv.HealthChecker = healthChecker
+	srv.Scheduler = scheduler
 
 	// start application
 	if err := srv.Listen(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
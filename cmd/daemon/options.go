@@ -5,10 +5,16 @@ import (
 	"fmt"
 	"github.com/BurntSushi/toml"
 	"github.com/go-acme/lego/v4/lego"
+	"github.com/jorenkoyen/conter/manager"
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+	"github.com/jorenkoyen/conter/proxy"
 	"github.com/jorenkoyen/go-logger"
 	"io"
 	"os"
+	"slices"
 	"strings"
+	"time"
 )
 
 // Options represents the CLI arguments.
@@ -31,16 +37,158 @@ type Config struct {
 		Email        string `toml:"email"`
 		DirectoryUrl string `toml:"directory_url"`
 		Insecure     bool   `toml:"insecure"`
+
+		// KeyType is the key type of requested certificates, ingress routes can override it.
+		KeyType  types.KeyType `toml:"key_type"`
+		ReuseKey bool          `toml:"reuse_key"` // reuse the private key when renewing certificates
+
+		// Issuers are the ACME servers in order of preference, 'directory_url' is used when no issuers are configured.
+		Issuers []IssuerConfig `toml:"issuers"`
+
+		DNS struct {
+			Provider                string            `toml:"provider"`
+			Resolvers               []string          `toml:"resolvers"`
+			DisablePropagationCheck bool              `toml:"disable_propagation_check"`
+			Credentials             map[string]string `toml:"credentials"`
+		} `toml:"dns"`
 	} `toml:"acme"`
 
 	Data struct {
 		Directory string `toml:"directory"`
 	} `toml:"data"`
 
+	// Encryption configures the master key protecting the private keys stored in the database.
+	Encryption struct {
+		KeyFile string `toml:"key_file"`
+		KeyEnv  string `toml:"key_env"` // name of the environment variable holding the key
+	} `toml:"encryption"`
+
 	Proxy struct {
 		HttpListenAddress  string `toml:"http_listen_address"`
 		HttpsListenAddress string `toml:"https_listen_address"`
+
+		// OnDemand issues certificates at the first TLS handshake for permitted domains without a certificate.
+		OnDemand struct {
+			Enabled        bool                `toml:"enabled"`
+			AllowedDomains []string            `toml:"allowed_domains"`
+			AskURL         string              `toml:"ask_url"`
+			Challenge      types.ChallengeType `toml:"challenge"`
+			Interval       time.Duration       `toml:"interval"`      // minimum time between attempts for the same domain
+			RequireRoute   bool                `toml:"require_route"` // only issue for domains with a route
+		} `toml:"on_demand"`
 	} `toml:"proxy"`
+
+	Notifications struct {
+		// Interval is the minimum time between two notifications for the same event and domains.
+		Interval time.Duration   `toml:"interval"`
+		Webhooks []WebhookConfig `toml:"webhooks"`
+
+		SMTP struct {
+			Address  string   `toml:"address"`
+			Username string   `toml:"username"`
+			Password string   `toml:"password"`
+			From     string   `toml:"from"`
+			To       []string `toml:"to"`
+		} `toml:"smtp"`
+	} `toml:"notifications"`
+
+	Scheduler struct {
+		BatchCertificates JobConfig `toml:"batch_certificates"`
+		CleanupChallenges JobConfig `toml:"cleanup_challenges"`
+		RetryOrders       JobConfig `toml:"retry_orders"`
+		RefreshOCSP       JobConfig `toml:"refresh_ocsp"`
+	} `toml:"scheduler"`
+}
+
+// IssuerConfig represents a single ACME server used for issuing certificates.
+type IssuerConfig struct {
+	Name         string `toml:"name"`
+	DirectoryUrl string `toml:"directory_url"`
+	EABKeyID     string `toml:"eab_key_id"`
+	EABHMACKey   string `toml:"eab_hmac_key"`
+}
+
+// AcmeIssuers will return the configured ACME issuers in order of preference.
+func (c *Config) AcmeIssuers() []manager.IssuerOptions {
+	if len(c.Acme.Issuers) == 0 {
+		return []manager.IssuerOptions{{Name: db.DefaultIssuer, Directory: c.Acme.DirectoryUrl}}
+	}
+
+	issuers := make([]manager.IssuerOptions, 0, len(c.Acme.Issuers))
+	for _, issuer := range c.Acme.Issuers {
+		issuers = append(issuers, manager.IssuerOptions{
+			Name:       issuer.Name,
+			Directory:  issuer.DirectoryUrl,
+			EABKeyID:   issuer.EABKeyID,
+			EABHMACKey: issuer.EABHMACKey,
+		})
+	}
+	return issuers
+}
+
+// EncryptionKey will return the master key used for encrypting private keys.
+// It will return nil if encryption has not been configured.
+func (c *Config) EncryptionKey() ([]byte, error) {
+	switch {
+	case c.Encryption.KeyFile != "":
+		content, err := os.ReadFile(c.Encryption.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+		return db.ParseEncryptionKey(content)
+
+	case c.Encryption.KeyEnv != "":
+		content, ok := os.LookupEnv(c.Encryption.KeyEnv)
+		if !ok || content == "" {
+			return nil, fmt.Errorf("environment variable %s for encryption key is not set", c.Encryption.KeyEnv)
+		}
+		return db.ParseEncryptionKey([]byte(content))
+
+	default:
+		return nil, nil
+	}
+}
+
+// WebhookConfig represents an endpoint receiving notifications as JSON POST request.
+type WebhookConfig struct {
+	URL     string            `toml:"url"`
+	Headers map[string]string `toml:"headers"`
+}
+
+// Notifiers will return all configured notifiers.
+func (c *Config) Notifiers() []manager.Notifier {
+	notifiers := make([]manager.Notifier, 0, len(c.Notifications.Webhooks)+1)
+	for _, webhook := range c.Notifications.Webhooks {
+		notifiers = append(notifiers, manager.NewWebhookNotifier(webhook.URL, webhook.Headers))
+	}
+
+	if smtp := c.Notifications.SMTP; smtp.Address != "" {
+		notifiers = append(notifiers, &manager.SMTPNotifier{
+			Address:  smtp.Address,
+			Username: smtp.Username,
+			Password: smtp.Password,
+			From:     smtp.From,
+			To:       smtp.To,
+		})
+	}
+
+	return notifiers
+}
+
+// JobConfig represents the schedule of a single maintenance job.
+type JobConfig struct {
+	Interval   time.Duration `toml:"interval"`
+	Jitter     time.Duration `toml:"jitter"`
+	RunOnStart bool          `toml:"run_on_start"`
+}
+
+// Options will convert the configuration into the scheduler job options.
+func (j JobConfig) Options() manager.JobOptions {
+	return manager.JobOptions{
+		Interval:   j.Interval,
+		Jitter:     j.Jitter,
+		RunOnStart: j.RunOnStart,
+	}
 }
 
 // Parse will process the CLI arguments and return the parsed options.
@@ -80,9 +228,17 @@ func ReadConfig(r io.Reader) (*Config, error) {
 	config.Acme.Email = "" // empty by default
 	config.Acme.DirectoryUrl = lego.LEDirectoryStaging
 	config.Acme.Insecure = false
+	config.Acme.KeyType = manager.DefaultKeyType
 	config.Data.Directory = "/var/lib/conter"
 	config.Proxy.HttpListenAddress = "0.0.0.0:80"
 	config.Proxy.HttpsListenAddress = "0.0.0.0:443"
+	config.Proxy.OnDemand.Challenge = types.ChallengeTypeHTTP
+	config.Proxy.OnDemand.Interval = proxy.DefaultOnDemandInterval
+	config.Notifications.Interval = manager.DefaultNotificationInterval
+	config.Scheduler.BatchCertificates = JobConfig{Interval: 12 * time.Hour, Jitter: time.Hour, RunOnStart: true}
+	config.Scheduler.CleanupChallenges = JobConfig{Interval: 15 * time.Minute, RunOnStart: true}
+	config.Scheduler.RetryOrders = JobConfig{Interval: time.Minute, RunOnStart: true}
+	config.Scheduler.RefreshOCSP = JobConfig{Interval: time.Hour, Jitter: 5 * time.Minute, RunOnStart: true}
 
 	_, err := toml.NewDecoder(r).Decode(config)
 	if err != nil {
@@ -97,15 +253,71 @@ func ReadConfig(r io.Reader) (*Config, error) {
 	if config.Acme.DirectoryUrl == "" {
 		warnings = append(warnings, "'acme.directory_url' is required")
 	}
+	names := make(map[string]bool, len(config.Acme.Issuers))
+	for i, issuer := range config.Acme.Issuers {
+		if issuer.Name == "" || names[issuer.Name] {
+			warnings = append(warnings, fmt.Sprintf("'acme.issuers[%d].name' is required and must be unique", i))
+		}
+		if issuer.DirectoryUrl == "" {
+			warnings = append(warnings, fmt.Sprintf("'acme.issuers[%d].directory_url' is required", i))
+		}
+		if (issuer.EABKeyID == "") != (issuer.EABHMACKey == "") {
+			warnings = append(warnings, fmt.Sprintf("'acme.issuers[%d]' requires both 'eab_key_id' and 'eab_hmac_key'", i))
+		}
+		names[issuer.Name] = true
+	}
+	if !slices.Contains(manager.SupportedKeyTypes, config.Acme.KeyType) {
+		warnings = append(warnings, fmt.Sprintf("'acme.key_type' must be one of %v", manager.SupportedKeyTypes))
+	}
+	if config.Acme.DNS.Provider != "" && !manager.IsDNSProviderSupported(config.Acme.DNS.Provider) {
+		warnings = append(warnings, fmt.Sprintf("'acme.dns.provider' must be one of %v", manager.DNSProviders()))
+	}
 	if config.Data.Directory == "" {
 		warnings = append(warnings, "'data.directory' is required")
 	}
+	if config.Encryption.KeyFile != "" && config.Encryption.KeyEnv != "" {
+		warnings = append(warnings, "'encryption' accepts only one of 'key_file' or 'key_env'")
+	}
 	if config.Proxy.HttpsListenAddress == "" {
 		warnings = append(warnings, "'proxy.https_listen_address' is required")
 	}
 	if config.Proxy.HttpListenAddress == "" {
 		warnings = append(warnings, "'proxy.http_listen_address' is required")
 	}
+	if od := config.Proxy.OnDemand; od.Enabled {
+		if len(od.AllowedDomains) == 0 && od.AskURL == "" {
+			warnings = append(warnings, "'proxy.on_demand' requires 'allowed_domains' or 'ask_url'")
+		}
+		if od.Challenge != types.ChallengeTypeHTTP && od.Challenge != types.ChallengeTypeTLS && od.Challenge != types.ChallengeTypeDNS {
+			warnings = append(warnings, fmt.Sprintf("'proxy.on_demand.challenge' must be one of %s, %s or %s", types.ChallengeTypeHTTP, types.ChallengeTypeTLS, types.ChallengeTypeDNS))
+		}
+		if od.Interval <= 0 {
+			warnings = append(warnings, "'proxy.on_demand.interval' must be positive")
+		}
+	}
+	if config.Notifications.Interval <= 0 {
+		warnings = append(warnings, "'notifications.interval' must be positive")
+	}
+	for i, webhook := range config.Notifications.Webhooks {
+		if webhook.URL == "" {
+			warnings = append(warnings, fmt.Sprintf("'notifications.webhooks[%d].url' is required", i))
+		}
+	}
+	if smtp := config.Notifications.SMTP; smtp.Address != "" && (smtp.From == "" || len(smtp.To) == 0) {
+		warnings = append(warnings, "'notifications.smtp' requires 'from' and 'to'")
+	}
+	if j := config.Scheduler.BatchCertificates; j.Interval <= 0 || j.Jitter < 0 {
+		warnings = append(warnings, "'scheduler.batch_certificates' requires a positive interval and jitter")
+	}
+	if j := config.Scheduler.CleanupChallenges; j.Interval <= 0 || j.Jitter < 0 {
+		warnings = append(warnings, "'scheduler.cleanup_challenges' requires a positive interval and jitter")
+	}
+	if j := config.Scheduler.RetryOrders; j.Interval <= 0 || j.Jitter < 0 {
+		warnings = append(warnings, "'scheduler.retry_orders' requires a positive interval and jitter")
+	}
+	if j := config.Scheduler.RefreshOCSP; j.Interval <= 0 || j.Jitter < 0 {
+		warnings = append(warnings, "'scheduler.refresh_ocsp' requires a positive interval and jitter")
+	}
 	if len(warnings) > 0 {
 		return nil, fmt.Errorf("missing properties: %s", strings.Join(warnings, ", "))
 	}
@@ -2,8 +2,13 @@ package main
 
 import (
 	"bytes"
+	"github.com/jorenkoyen/conter/manager"
+	"github.com/jorenkoyen/conter/manager/types"
+	"github.com/jorenkoyen/conter/proxy"
 	"github.com/jorenkoyen/go-logger"
+	"strings"
 	"testing"
+	"time"
 )
 
 func AssertEquals(t *testing.T, expected interface{}, actual interface{}) {
@@ -24,12 +29,24 @@ email 			= "user@example.com"
 directory_url 	= "https://acme.com/directory"
 insecure 		= false
 
+[acme.dns]
+provider 	= "exec"
+resolvers 	= ["127.0.0.1:8053"]
+
+[acme.dns.credentials]
+EXEC_PATH = "/usr/local/bin/dns.sh"
+
 [data]
 directory = "/var/lib/conter"
 
 [proxy]
 http_listen_address 	= "0.0.0.0:80"
 https_listen_address 	= "0.0.0.0:443"
+
+[scheduler.batch_certificates]
+interval 		= "6h"
+jitter 			= "10m"
+run_on_start 	= false
 `
 	buf := bytes.NewBufferString(valid)
 	config, err := ReadConfig(buf)
@@ -47,6 +64,9 @@ https_listen_address 	= "0.0.0.0:443"
 	AssertEquals(t, "user@example.com", config.Acme.Email)
 	AssertEquals(t, "https://acme.com/directory", config.Acme.DirectoryUrl)
 	AssertEquals(t, false, config.Acme.Insecure)
+	AssertEquals(t, "exec", config.Acme.DNS.Provider)
+	AssertEquals(t, "127.0.0.1:8053", config.Acme.DNS.Resolvers[0])
+	AssertEquals(t, "/usr/local/bin/dns.sh", config.Acme.DNS.Credentials["EXEC_PATH"])
 
 	// data
 	AssertEquals(t, "/var/lib/conter", config.Data.Directory)
@@ -54,6 +74,193 @@ https_listen_address 	= "0.0.0.0:443"
 	// proxy
 	AssertEquals(t, "0.0.0.0:80", config.Proxy.HttpListenAddress)
 	AssertEquals(t, "0.0.0.0:443", config.Proxy.HttpsListenAddress)
+
+	// scheduler
+	AssertEquals(t, 6*time.Hour, config.Scheduler.BatchCertificates.Interval)
+	AssertEquals(t, 10*time.Minute, config.Scheduler.BatchCertificates.Jitter)
+	AssertEquals(t, false, config.Scheduler.BatchCertificates.RunOnStart)
+	AssertEquals(t, 15*time.Minute, config.Scheduler.CleanupChallenges.Interval)
+}
+
+func TestCheckConfig_invalidSchedulerInterval(t *testing.T) {
+	invalid := `
+[scheduler.cleanup_challenges]
+interval = "0s"
+`
+	buf := bytes.NewBufferString(invalid)
+	_, err := ReadConfig(buf)
+	if err == nil {
+		t.Errorf("Configuration without a scheduler interval should not be considered valid")
+	}
+}
+
+func TestCheckConfig_issuers(t *testing.T) {
+	valid := `
+[acme]
+email = "user@example.com"
+
+[[acme.issuers]]
+name 			= "zerossl"
+directory_url 	= "https://acme.zerossl.com/v2/DV90"
+eab_key_id 		= "kid"
+eab_hmac_key 	= "hmac"
+
+[[acme.issuers]]
+name 			= "letsencrypt"
+directory_url 	= "https://acme-v02.api.letsencrypt.org/directory"
+`
+	buf := bytes.NewBufferString(valid)
+	config, err := ReadConfig(buf)
+	if err != nil {
+		t.Errorf("Failed to read configuration file: %v", err)
+		t.FailNow()
+	}
+
+	issuers := config.AcmeIssuers()
+	AssertEquals(t, 2, len(issuers))
+	AssertEquals(t, "zerossl", issuers[0].Name)
+	AssertEquals(t, "kid", issuers[0].EABKeyID)
+	AssertEquals(t, "hmac", issuers[0].EABHMACKey)
+	AssertEquals(t, "letsencrypt", issuers[1].Name)
+	AssertEquals(t, "", issuers[1].EABKeyID)
+}
+
+func TestCheckConfig_invalidIssuers(t *testing.T) {
+	invalid := `
+[[acme.issuers]]
+name 			= "zerossl"
+directory_url 	= "https://acme.zerossl.com/v2/DV90"
+eab_key_id 		= "kid"
+
+[[acme.issuers]]
+name 			= "zerossl"
+directory_url 	= ""
+`
+	buf := bytes.NewBufferString(invalid)
+	_, err := ReadConfig(buf)
+	if err == nil {
+		t.Errorf("Configuration with invalid issuers should not be considered valid")
+		t.FailNow()
+	}
+
+	for _, field := range []string{"acme.issuers[0]", "acme.issuers[1].name", "acme.issuers[1].directory_url"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected error for '%s', got: %v", field, err)
+		}
+	}
+}
+
+func TestCheckConfig_notifications(t *testing.T) {
+	valid := `
+[notifications]
+interval = "6h"
+
+[[notifications.webhooks]]
+url = "https://hooks.example.com/conter"
+headers = { Authorization = "Bearer token" }
+
+[notifications.smtp]
+address  = "smtp.example.com:587"
+username = "conter"
+password = "secret"
+from     = "conter@example.com"
+to       = ["ops@example.com"]
+`
+	buf := bytes.NewBufferString(valid)
+	config, err := ReadConfig(buf)
+	if err != nil {
+		t.Errorf("Failed to read configuration file: %v", err)
+		t.FailNow()
+	}
+
+	AssertEquals(t, 6*time.Hour, config.Notifications.Interval)
+	notifiers := config.Notifiers()
+	AssertEquals(t, 2, len(notifiers))
+	AssertEquals(t, "webhook", notifiers[0].Name())
+	AssertEquals(t, "Bearer token", notifiers[0].(*manager.WebhookNotifier).Headers["Authorization"])
+	AssertEquals(t, "smtp", notifiers[1].Name())
+}
+
+func TestCheckConfig_invalidNotifications(t *testing.T) {
+	invalid := `
+[[notifications.webhooks]]
+url = ""
+
+[notifications.smtp]
+address = "smtp.example.com:587"
+`
+	buf := bytes.NewBufferString(invalid)
+	_, err := ReadConfig(buf)
+	if err == nil {
+		t.Errorf("Configuration with invalid notifications should not be considered valid")
+		t.FailNow()
+	}
+
+	for _, field := range []string{"notifications.webhooks[0].url", "notifications.smtp"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected error for '%s', got: %v", field, err)
+		}
+	}
+}
+
+func TestCheckConfig_onDemand(t *testing.T) {
+	valid := `
+[proxy.on_demand]
+enabled         = true
+allowed_domains = ["*.customers.example.com"]
+ask_url         = "http://127.0.0.1:9000/allowed"
+challenge       = "TLS-ALPN-01"
+`
+	buf := bytes.NewBufferString(valid)
+	config, err := ReadConfig(buf)
+	if err != nil {
+		t.Errorf("Failed to read configuration file: %v", err)
+		t.FailNow()
+	}
+
+	AssertEquals(t, types.ChallengeTypeTLS, config.Proxy.OnDemand.Challenge)
+	AssertEquals(t, proxy.DefaultOnDemandInterval, config.Proxy.OnDemand.Interval)
+
+	invalid := `
+[proxy.on_demand]
+enabled   = true
+challenge = "MANUAL"
+`
+	_, err = ReadConfig(bytes.NewBufferString(invalid))
+	if err == nil {
+		t.Errorf("Configuration with invalid on-demand settings should not be considered valid")
+		t.FailNow()
+	}
+
+	for _, field := range []string{"proxy.on_demand' requires", "proxy.on_demand.challenge"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected error for '%s', got: %v", field, err)
+		}
+	}
+}
+
+func TestCheckConfig_invalidDNSProvider(t *testing.T) {
+	invalid := `
+[acme.dns]
+provider = "unknown"
+`
+	buf := bytes.NewBufferString(invalid)
+	_, err := ReadConfig(buf)
+	if err == nil {
+		t.Errorf("Configuration with unknown DNS provider should not be considered valid")
+	}
+}
+
+func TestCheckConfig_invalidKeyType(t *testing.T) {
+	invalid := `
+[acme]
+key_type = "EC521"
+`
+	buf := bytes.NewBufferString(invalid)
+	_, err := ReadConfig(buf)
+	if err == nil {
+		t.Errorf("Configuration with unknown key type should not be considered valid")
+	}
 }
 
 func TestCheckConfig_invalid(t *testing.T) {
@@ -99,6 +306,11 @@ func TestCheckConfig_default(t *testing.T) {
 	AssertEquals(t, "", config.Acme.Email)
 	AssertEquals(t, "https://acme-staging-v02.api.letsencrypt.org/directory", config.Acme.DirectoryUrl)
 	AssertEquals(t, false, config.Acme.Insecure)
+	AssertEquals(t, manager.DefaultKeyType, config.Acme.KeyType)
+	AssertEquals(t, false, config.Acme.ReuseKey)
+	AssertEquals(t, 1, len(config.AcmeIssuers()))
+	AssertEquals(t, "default", config.AcmeIssuers()[0].Name)
+	AssertEquals(t, config.Acme.DirectoryUrl, config.AcmeIssuers()[0].Directory)
 
 	// data
 	AssertEquals(t, "/var/lib/conter", config.Data.Directory)
@@ -106,6 +318,16 @@ func TestCheckConfig_default(t *testing.T) {
 	// proxy
 	AssertEquals(t, "0.0.0.0:80", config.Proxy.HttpListenAddress)
 	AssertEquals(t, "0.0.0.0:443", config.Proxy.HttpsListenAddress)
+
+	// scheduler
+	AssertEquals(t, 12*time.Hour, config.Scheduler.BatchCertificates.Interval)
+	AssertEquals(t, time.Hour, config.Scheduler.BatchCertificates.Jitter)
+	AssertEquals(t, true, config.Scheduler.BatchCertificates.RunOnStart)
+	AssertEquals(t, true, config.Scheduler.CleanupChallenges.RunOnStart)
+
+	// notifications
+	AssertEquals(t, 24*time.Hour, config.Notifications.Interval)
+	AssertEquals(t, 0, len(config.Notifiers()))
 }
 
 func TestParse(t *testing.T) {
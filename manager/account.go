@@ -0,0 +1,334 @@
+package manager
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+// AcmeAccount contains the information of the ACME account registered with an issuer.
+type AcmeAccount struct {
+	Issuer    string
+	Directory string
+	Email     string
+	URI       string
+	Status    string
+	Contact   []string
+	// KeyThumbprint is the base64url encoded SHA-256 thumbprint of the account key (RFC 7638).
+	KeyThumbprint string
+}
+
+// UpdateAccountOptions contains the changes to apply to an ACME account.
+// The changes are applied in the order email, key rollover and deactivation.
+type UpdateAccountOptions struct {
+	Email       string `json:"email"`
+	RolloverKey bool   `json:"rollover_key"`
+	Deactivate  bool   `json:"deactivate"`
+}
+
+// validate will perform the basic validation required for updating an ACME account.
+func (opts *UpdateAccountOptions) validate() *types.ValidationError {
+	err := new(types.ValidationError)
+
+	if opts.Email == "" && !opts.RolloverKey && !opts.Deactivate {
+		err.Append("email", "At least one change to the account is required")
+	}
+	if opts.Email != "" && !strings.Contains(opts.Email, "@") {
+		err.Append("email", "A valid email address is required")
+	}
+
+	if err.HasFailures() {
+		return err
+	}
+
+	return nil
+}
+
+// Account will retrieve the ACME account of the issuer from the ACME server, an empty name selects the preferred issuer.
+// The latest state of the account is persisted.
+func (c *CertificateManager) Account(name string) (*AcmeAccount, error) {
+	iss := c.issuer(name)
+	if iss == nil {
+		return nil, fmt.Errorf("issuer=%s is not configured", name)
+	}
+
+	reg, err := iss.client(types.ChallengeTypeHTTP).Registration.QueryRegistration()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ACME account: %w", err)
+	}
+
+	user := *iss.currentUser()
+	user.Registration = reg
+	iss.setUser(&user)
+	iss.config.SetAcmeRegistration(reg)
+	return iss.account(), nil
+}
+
+// UpdateAccount will apply the changes to the ACME account of the issuer, an empty name selects the preferred issuer.
+// A deactivated account can no longer be used, the issuer is removed until the daemon registers a new account on restart.
+func (c *CertificateManager) UpdateAccount(name string, opts UpdateAccountOptions) (*AcmeAccount, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	iss := c.issuer(name)
+	if iss == nil {
+		return nil, fmt.Errorf("issuer=%s is not configured", name)
+	}
+
+	if opts.Email != "" && opts.Email != iss.currentUser().Email {
+		if err := c.updateEmail(iss, opts.Email); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.RolloverKey {
+		if err := c.rolloverKey(iss); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Deactivate {
+		if err := c.deactivate(iss); err != nil {
+			return nil, err
+		}
+	}
+
+	return iss.account(), nil
+}
+
+// updateEmail will change the contact address of the ACME account.
+func (c *CertificateManager) updateEmail(iss *issuer, email string) error {
+	previous := iss.currentUser()
+	user := *previous
+	user.Email = email // used as contact by LEGO
+
+	client, err := c.newClient(iss, &user)
+	if err != nil {
+		return fmt.Errorf("unable to create LEGO client: %w", err)
+	}
+
+	reg, err := client.Registration.UpdateRegistration(registration.RegisterOptions{TermsOfServiceAgreed: true})
+	if err != nil {
+		return fmt.Errorf("failed to update ACME account: %w", err)
+	}
+
+	c.logger.Infof("Updated ACME account email=%s (issuer=%s, previous=%s)", email, iss.name, previous.Email)
+	user.Registration = reg
+	iss.setUser(&user)
+	iss.config.SetAcmeEmail(email)
+	iss.config.SetAcmeRegistration(reg)
+	return nil
+}
+
+// rolloverKey will replace the key of the ACME account with a newly generated key (RFC 8555, section 7.3.5).
+func (c *CertificateManager) rolloverKey(iss *issuer) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	previous := iss.currentUser()
+	if err = changeKey(c.httpClient(), iss.directory, previous, key); err != nil {
+		return fmt.Errorf("failed to rollover ACME account key: %w", err)
+	}
+
+	// the clients keep using the previous key when they can not be recreated
+	user := *previous
+	user.PrivateKey = key
+	if err = c.reconnect(iss, &user); err != nil {
+		return fmt.Errorf("failed to use rolled over ACME account key: %w", err)
+	}
+
+	iss.config.SetAcmePrivateKey(key)
+	c.logger.Infof("Rolled over ACME account key (issuer=%s, uri=%s)", iss.name, user.Registration.URI)
+	return nil
+}
+
+// deactivate will deactivate the ACME account and remove the issuer.
+func (c *CertificateManager) deactivate(iss *issuer) error {
+	if err := iss.client(types.ChallengeTypeHTTP).Registration.DeleteRegistration(); err != nil {
+		return fmt.Errorf("failed to deactivate ACME account: %w", err)
+	}
+
+	user := *iss.currentUser()
+	reg := *user.Registration
+	reg.Body.Status = acme.StatusDeactivated
+	user.Registration = &reg
+	iss.setUser(&user)
+
+	c.logger.Warningf("Deactivated ACME account (issuer=%s, uri=%s), certificates will no longer be requested from the issuer", iss.name, reg.URI)
+	iss.config.ClearAcme()
+
+	c.issuersMutex.Lock()
+	defer c.issuersMutex.Unlock()
+	c.issuers = slices.DeleteFunc(slices.Clone(c.issuers), func(other *issuer) bool { return other == iss })
+	return nil
+}
+
+// account will return the account information of the issuer.
+func (iss *issuer) account() *AcmeAccount {
+	user := iss.currentUser()
+	account := &AcmeAccount{
+		Issuer:    iss.name,
+		Directory: iss.directory,
+		Email:     user.Email,
+	}
+
+	if reg := user.Registration; reg != nil {
+		account.URI = reg.URI
+		account.Status = reg.Body.Status
+		account.Contact = reg.Body.Contact
+	}
+
+	if signer, ok := user.PrivateKey.(crypto.Signer); ok {
+		jwk := jose.JSONWebKey{Key: signer.Public()}
+		if thumbprint, err := jwk.Thumbprint(crypto.SHA256); err == nil {
+			account.KeyThumbprint = base64.RawURLEncoding.EncodeToString(thumbprint)
+		}
+	}
+
+	return account
+}
+
+// changeKey will send the key change request for the account to the ACME server.
+// The request is signed by the current key and contains the inner request signed by the new key.
+func changeKey(client *http.Client, directory string, user *types.AcmeRegistration, key *ecdsa.PrivateKey) error {
+	if user.Registration == nil {
+		return errors.New("account is not registered")
+	}
+
+	current, ok := user.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("unsupported account key type %T", user.PrivateKey)
+	}
+
+	dir := new(acme.Directory)
+	if err := getJSON(client, directory, dir); err != nil {
+		return fmt.Errorf("failed to retrieve directory: %w", err)
+	}
+	if dir.KeyChangeURL == "" {
+		return errors.New("ACME server does not support key changes")
+	}
+
+	// inner request, signed by the new key
+	payload, err := json.Marshal(map[string]any{
+		"account": user.Registration.URI,
+		"oldKey":  jose.JSONWebKey{Key: current.Public()},
+	})
+	if err != nil {
+		return err
+	}
+
+	inner, err := sign(key, jose.JSONWebKey{Key: key}, dir.KeyChangeURL, nil, payload)
+	if err != nil {
+		return err
+	}
+
+	// outer request, signed by the current key
+	nonce, err := getNonce(client, dir.NewNonceURL)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve nonce: %w", err)
+	}
+
+	outer, err := sign(current, jose.JSONWebKey{Key: current, KeyID: user.Registration.URI}, dir.KeyChangeURL, staticNonce(nonce), []byte(inner.FullSerialize()))
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(dir.KeyChangeURL, "application/jose+json", strings.NewReader(outer.FullSerialize()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		problem := new(acme.ProblemDetails)
+		body, _ := io.ReadAll(resp.Body)
+		if err = json.Unmarshal(body, problem); err != nil || problem.Detail == "" {
+			return fmt.Errorf("unexpected status code %d from ACME server: %s", resp.StatusCode, bytes.TrimSpace(body))
+		}
+		return problem
+	}
+
+	return nil
+}
+
+// sign will create the JWS for the ACME request, a key without ID is embedded as JWK.
+func sign(key *ecdsa.PrivateKey, jwk jose.JSONWebKey, url string, nonce jose.NonceSource, payload []byte) (*jose.JSONWebSignature, error) {
+	var alg jose.SignatureAlgorithm
+	switch key.Curve {
+	case elliptic.P256():
+		alg = jose.ES256
+	case elliptic.P384():
+		alg = jose.ES384
+	default:
+		return nil, fmt.Errorf("unsupported curve %s", key.Curve.Params().Name)
+	}
+
+	opts := &jose.SignerOptions{
+		NonceSource:  nonce,
+		EmbedJWK:     jwk.KeyID == "",
+		ExtraHeaders: map[jose.HeaderKey]any{"url": url},
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: jwk}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signer: %w", err)
+	}
+
+	return signer.Sign(payload)
+}
+
+// staticNonce provides the nonce for a single signature.
+type staticNonce string
+
+func (n staticNonce) Nonce() (string, error) {
+	return string(n), nil
+}
+
+// getNonce will request a fresh nonce from the ACME server.
+func getNonce(client *http.Client, url string) (string, error) {
+	resp, err := client.Head(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", errors.New("server did not respond with a nonce")
+	}
+
+	return nonce, nil
+}
+
+// getJSON will decode the JSON response of the URL into the output.
+func getJSON(client *http.Client, url string, output any) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(output)
+}
@@ -0,0 +1,191 @@
+package manager
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+func TestChangeKey(t *testing.T) {
+	current, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	algorithms := []jose.SignatureAlgorithm{jose.ES256}
+
+	var changed bool
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	accountURL := server.URL + "/account/1"
+	mux.HandleFunc("GET /directory", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(acme.Directory{NewNonceURL: server.URL + "/nonce", KeyChangeURL: server.URL + "/key-change"})
+	})
+	mux.HandleFunc("HEAD /nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce-1")
+	})
+	mux.HandleFunc("POST /key-change", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		outer, err := jose.ParseSigned(string(body), algorithms)
+		if err != nil {
+			t.Errorf("Failed to parse outer JWS: %v", err)
+			return
+		}
+
+		header := outer.Signatures[0].Protected
+		AssertEquals(t, accountURL, header.KeyID)
+		AssertEquals(t, "nonce-1", header.Nonce)
+		AssertEquals(t, server.URL+"/key-change", header.ExtraHeaders["url"])
+
+		content, err := outer.Verify(current.Public())
+		if err != nil {
+			t.Errorf("Expected outer JWS to be signed by the current key: %v", err)
+			return
+		}
+
+		inner, err := jose.ParseSigned(string(content), algorithms)
+		if err != nil {
+			t.Errorf("Failed to parse inner JWS: %v", err)
+			return
+		}
+
+		payload, err := inner.Verify(key.Public())
+		if err != nil {
+			t.Errorf("Expected inner JWS to be signed by the new key: %v", err)
+			return
+		}
+
+		var request struct {
+			Account string          `json:"account"`
+			OldKey  jose.JSONWebKey `json:"oldKey"`
+		}
+		if err = json.Unmarshal(payload, &request); err != nil {
+			t.Errorf("Failed to decode key change: %v", err)
+			return
+		}
+
+		AssertEquals(t, accountURL, request.Account)
+		if !current.PublicKey.Equal(request.OldKey.Key) {
+			t.Errorf("Expected old key to match the current key")
+		}
+
+		changed = true
+	})
+
+	user := &types.AcmeRegistration{
+		Email:        "admin@example.com",
+		PrivateKey:   current,
+		Registration: &registration.Resource{URI: accountURL},
+	}
+
+	if err := changeKey(server.Client(), server.URL+"/directory", user, key); err != nil {
+		t.Fatalf("Failed to change key: %v", err)
+	}
+	if !changed {
+		t.Errorf("Expected key change request to be sent")
+	}
+}
+
+func TestChangeKey_problem(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("GET /directory", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(acme.Directory{NewNonceURL: server.URL + "/nonce", KeyChangeURL: server.URL + "/key-change"})
+	})
+	mux.HandleFunc("HEAD /nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce-1")
+	})
+	mux.HandleFunc("POST /key-change", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(acme.ProblemDetails{Type: "urn:ietf:params:acme:error:malformed", Detail: "key already in use"})
+	})
+
+	current, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	user := &types.AcmeRegistration{PrivateKey: current, Registration: &registration.Resource{URI: server.URL + "/account/1"}}
+
+	err := changeKey(server.Client(), server.URL+"/directory", user, key)
+	if _, ok := err.(*acme.ProblemDetails); !ok {
+		t.Errorf("Expected ACME problem to be returned, got: %v", err)
+	}
+}
+
+func TestCertificateManager_rolloverKey_reconnectFailed(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("GET /directory", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(acme.Directory{
+			NewNonceURL:   server.URL + "/nonce",
+			NewAccountURL: server.URL + "/account",
+			NewOrderURL:   server.URL + "/order",
+			KeyChangeURL:  server.URL + "/key-change",
+		})
+	})
+	mux.HandleFunc("HEAD /nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce-1")
+	})
+	mux.HandleFunc("POST /key-change", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	current, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	config := db.NewConfigDatabase(database)
+	config.SetAcmePrivateKey(current)
+
+	iss := &issuer{
+		name:      "test",
+		directory: server.URL + "/directory",
+		config:    config,
+		user:      &types.AcmeRegistration{PrivateKey: current, Registration: &registration.Resource{URI: server.URL + "/account/1"}},
+		clients:   make(map[types.ChallengeType]*lego.Client),
+		providers: map[types.ChallengeType]func(client *lego.Client) error{
+			types.ChallengeTypeHTTP: func(client *lego.Client) error { return errors.New("provider unavailable") },
+		},
+	}
+
+	if err := mgr.rolloverKey(iss); err == nil {
+		t.Fatalf("Expected rollover to fail when the clients can not be recreated")
+	}
+
+	// the previous key is kept in memory and in the database
+	if !current.Equal(iss.currentUser().PrivateKey) {
+		t.Errorf("Expected the previous account key to be kept")
+	}
+	if persisted, err := config.GetAcmePrivateKey(); err != nil || !current.Equal(persisted) {
+		t.Errorf("Expected the previous account key to be persisted")
+	}
+}
+
+func TestUpdateAccountOptions_validate(t *testing.T) {
+	opts := UpdateAccountOptions{}
+	AssertErrorThrownForField(t, opts.validate(), "email")
+
+	opts = UpdateAccountOptions{Email: "invalid"}
+	AssertErrorThrownForField(t, opts.validate(), "email")
+
+	opts = UpdateAccountOptions{RolloverKey: true}
+	if err := opts.validate(); err != nil {
+		t.Errorf("Expected no validation error, got: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package manager
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/google/uuid"
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+const (
+	// InternalIssuer is the name of the issuer recorded on certificates signed by the internal certificate authority.
+	InternalIssuer = "internal"
+
+	internalRootName = "Conter Internal Root CA"
+)
+
+var (
+	// InternalRootValidity is the lifetime of the root certificate of the internal certificate authority.
+	InternalRootValidity = 10 * 365 * 24 * time.Hour
+	// InternalCertificateValidity is the lifetime of a certificate issued by the internal certificate authority.
+	InternalCertificateValidity = 365 * 24 * time.Hour
+)
+
+// authority is the root certificate and key of the internal certificate authority.
+type authority struct {
+	certificate *x509.Certificate
+	key         crypto.Signer
+	pem         []byte
+}
+
+// CertificateAuthority will return the PEM encoded root certificate of the internal certificate authority.
+// The certificate authority is created when it does not exist yet.
+func (c *CertificateManager) CertificateAuthority() ([]byte, error) {
+	ca, err := c.authority()
+	if err != nil {
+		return nil, err
+	}
+
+	return ca.pem, nil
+}
+
+// IssueInternal will issue a certificate for the domains signed by the internal certificate authority.
+// The key settings are completed with the defaults of the daemon, they are kept for renewing the certificate.
+// The certificate is persisted for each domain and replaces any existing certificate.
+func (c *CertificateManager) IssueInternal(domains []string, opts types.KeyOptions) (*types.Certificate, error) {
+	ca, err := c.authority()
+	if err != nil {
+		return nil, err
+	}
+
+	opts = c.keyOptions(opts)
+	key, err := c.privateKey(opts, c.get(domains[0]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create private key: %w", err)
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: domains[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(InternalCertificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              domains,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, key.(crypto.Signer).Public(), ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	cert := &types.Certificate{
+		ID:            uuid.NewString(),
+		Certificate:   base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Key:           base64.StdEncoding.EncodeToString(certcrypto.PEMEncode(key)),
+		ChallengeType: types.ChallengeTypeInternal,
+		Domains:       domains,
+		Issuer:        InternalIssuer,
+		KeyType:       opts.Type,
+		ReuseKey:      opts.Reuse,
+	}
+
+	if err = c.data.SetCertificate(cert); err != nil {
+		return nil, fmt.Errorf("failed to save certificate: %w", err)
+	}
+
+	c.logger.Infof("Issued internal certificate with id=%s (domains=%v, key_type=%s, expiry=%s)", cert.ID, domains, opts.Type, template.NotAfter.Format(time.RFC3339))
+	return cert, nil
+}
+
+// authority will load the internal certificate authority, it is generated and persisted on first use.
+func (c *CertificateManager) authority() (*authority, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	config := db.NewConfigDatabase(c.data)
+	certPEM, keyPEM := config.GetCertificateAuthority()
+	if certPEM == nil || keyPEM == nil {
+		c.logger.Infof("No internal certificate authority found, generating new root certificate")
+
+		var err error
+		certPEM, keyPEM, err = generateAuthority()
+		if err != nil {
+			return nil, err
+		}
+
+		config.SetCertificateAuthority(certPEM, keyPEM)
+	}
+
+	certificate, err := certcrypto.ParsePEMCertificate(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	key, err := certcrypto.ParsePEMPrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("CA private key can not be used for signing")
+	}
+
+	return &authority{certificate: certificate, key: signer, pem: certPEM}, nil
+}
+
+// generateAuthority will create a new self-signed root certificate and return it with its private key in PEM format.
+func generateAuthority() ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA private key: %w", err)
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: internalRootName, Organization: []string{"conter"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(InternalRootValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), certcrypto.PEMEncode(key), nil
+}
+
+// serialNumber will generate a random 128-bit certificate serial number.
+func serialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	return serial, nil
+}
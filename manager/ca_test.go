@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+func TestCertificateManager_IssueInternal(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	if err := mgr.ChallengeCreate([]string{"app.internal", "*.app.internal"}, types.ChallengeTypeInternal, types.KeyOptions{}); err != nil {
+		t.Fatalf("Failed to issue internal certificate: %v", err)
+	}
+
+	cert := mgr.Get("api.app.internal")
+	if cert == nil {
+		t.Fatalf("Expected internal certificate to be stored for wildcard domain")
+	}
+	AssertEquals(t, types.ChallengeTypeInternal, cert.ChallengeType)
+	AssertEquals(t, InternalIssuer, cert.Issuer)
+
+	if _, err := cert.X509KeyPair(); err != nil {
+		t.Fatalf("Expected a valid key pair: %v", err)
+	}
+
+	root, err := mgr.CertificateAuthority()
+	if err != nil {
+		t.Fatalf("Failed to retrieve certificate authority: %v", err)
+	}
+
+	ca, err := certcrypto.ParsePEMCertificate(root)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate authority: %v", err)
+	}
+
+	{
+		// leaf certificate is trusted by the root
+		leaf, err := cert.Parse()
+		if err != nil {
+			t.Fatalf("Failed to parse certificate: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		pool.AddCert(ca)
+		if _, err = leaf.Verify(x509.VerifyOptions{Roots: pool, DNSName: "api.app.internal"}); err != nil {
+			t.Errorf("Expected certificate to be verified by the root: %v", err)
+		}
+
+		if time.Until(leaf.NotAfter) < ExpiryCutOff {
+			t.Errorf("Expected certificate to outlive the renewal cut off, expiry=%s", leaf.NotAfter)
+		}
+	}
+
+	{
+		// root is reused by other managers on the same database
+		other := NewCertificateManger(database, "", nil, false)
+		again, err := other.CertificateAuthority()
+		if err != nil {
+			t.Fatalf("Failed to retrieve certificate authority: %v", err)
+		}
+
+		if !bytes.Equal(root, again) {
+			t.Errorf("Expected the root certificate to be persisted")
+		}
+	}
+	{
+		// key settings are applied to the issued certificate
+		opts := types.KeyOptions{Type: types.KeyTypeEC384, Reuse: true}
+		issued, err := mgr.IssueInternal([]string{"key.internal"}, opts)
+		if err != nil {
+			t.Fatalf("Failed to issue internal certificate: %v", err)
+		}
+
+		leaf, err := issued.Parse()
+		if err != nil {
+			t.Fatalf("Failed to parse certificate: %v", err)
+		}
+		AssertEquals(t, types.KeyTypeEC384, types.KeyTypeOf(leaf.PublicKey))
+		AssertEquals(t, opts, issued.KeyOptions())
+
+		// the key is reused when the certificate is issued again
+		renewed, err := mgr.IssueInternal([]string{"key.internal"}, issued.KeyOptions())
+		if err != nil {
+			t.Fatalf("Failed to issue internal certificate: %v", err)
+		}
+		AssertEquals(t, issued.Key, renewed.Key)
+	}
+}
@@ -1,24 +1,17 @@
 package manager
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
+	"context"
 	"crypto/tls"
-	"encoding/base64"
 	"errors"
 	"fmt"
-	"github.com/go-acme/lego/v4/certificate"
-	"github.com/go-acme/lego/v4/lego"
-	"github.com/go-acme/lego/v4/registration"
-	"github.com/google/uuid"
+	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
 	"github.com/jorenkoyen/conter/manager/db"
 	"github.com/jorenkoyen/conter/manager/types"
-	"github.com/jorenkoyen/conter/version"
 	"github.com/jorenkoyen/go-logger"
 	"github.com/jorenkoyen/go-logger/log"
-	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -26,118 +19,63 @@ var (
 	// ExpiryCutOff is the duration until the expiry date until we will renew the certificate.
 	// This is currently set to 30 days, any certificate that will expire within 30 days will get renewed.
 	ExpiryCutOff = time.Hour * 24 * 30
+
+	// StaleChallengeAge is the age after which a pending ACME challenge is considered abandoned.
+	// A stale challenge would otherwise block new certificate requests for the domain.
+	StaleChallengeAge = time.Hour
 )
 
 type CertificateManager struct {
-	logger *logger.Logger
-	config *db.Config
-	data   *db.Client
-	acme   *lego.Client
+	logger  *logger.Logger
+	data    *db.Client
+	issuers []*issuer // in order of preference
 
-	directory string
-	insecure  bool
-}
+	mutex    sync.Mutex
+	inflight map[string]struct{} // order keys currently being obtained
 
-func NewCertificateManger(database *db.Client, email string, directory string, insecure bool) *CertificateManager {
-	mgr := &CertificateManager{
-		logger:    log.WithName("certificate-mgr"),
-		config:    db.NewConfigDatabase(database),
-		data:      database,
-		directory: directory,
-		insecure:  insecure,
-	}
+	issuersMutex sync.RWMutex // guards the issuers, an issuer is removed when its account is deactivated
 
-	if email == "" {
-		mgr.logger.Warningf("No ACME email address set, please update configuration before requesting certificates")
-	} else {
-		// check if user is initialized (if not already done)
-		mgr.acme = mgr.init(email, false)
-	}
+	insecure bool
 
-	return mgr
-}
+	// KeyType is the key type used for requesting certificates that do not specify one.
+	KeyType types.KeyType
+	// ReuseKey will reuse the private key of the existing certificate when renewing any certificate.
+	ReuseKey bool
 
-// registration will return the current ACME registration resource based on the stored data.
-func (c *CertificateManager) registration() *types.AcmeRegistration {
-	return &types.AcmeRegistration{
-		Email:        c.config.GetAcmeEmail(),
-		PrivateKey:   c.config.GetAcmePrivateKey(),
-		Registration: c.config.GetAcmeRegistration(),
-	}
+	Notifications *NotificationManager
 }
 
-// init will initialize the certificate manager by registering the user with the ACME registry.
-// If the user is already registered no action will be undertaken.
-func (c *CertificateManager) init(email string, isRetry bool) *lego.Client {
-	user := c.registration()
-	if user.Email != email || !user.IsValid() || c.directory != c.config.GetAcmeDirectory() {
-		c.logger.Infof("Initializing ACME user for email=%s", email)
-		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-		if err != nil {
-			c.logger.Fatalf("Failed to generate private key: %v", err)
-		}
-
-		user = &types.AcmeRegistration{
-			Email:      email,
-			PrivateKey: privateKey,
-		}
-	}
-
-	// continue LEGO configuration
-	config := lego.NewConfig(user)
-	config.CADirURL = c.directory
-	config.UserAgent = fmt.Sprintf("conter/%s", version.Version)
-	config.HTTPClient = &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: c.insecure,
-			},
-		},
+func NewCertificateManger(database *db.Client, email string, issuers []IssuerOptions, insecure bool) *CertificateManager {
+	mgr := &CertificateManager{
+		logger:   log.WithName("certificate-mgr"),
+		data:     database,
+		inflight: make(map[string]struct{}),
+		insecure: insecure,
+		KeyType:  DefaultKeyType,
 	}
 
-	c.logger.Tracef("Creating ACME client for directory=%s (email=%s)", config.CADirURL, user.Email)
-	client, err := lego.NewClient(config)
-	if err != nil {
-		c.logger.Fatalf("Unable to create LEGO client: %v", err)
+	if email == "" {
+		mgr.logger.Warningf("No ACME email address set, please update configuration before requesting certificates")
+		return mgr
 	}
 
-	// check if we require registration
-	if user.GetRegistration() == nil {
-		reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
-		if err != nil {
-			c.logger.Fatalf("Failed to register with ACME: %v", err)
-		}
-
-		c.logger.Debugf("Successfully registered with ACME registry (uri=%s)", reg.URI)
-		user.Registration = reg
-
-		// persist all information
-		c.config.SetAcmeEmail(user.Email)
-		c.config.SetAcmePrivateKey(user.PrivateKey)
-		c.config.SetAcmeRegistration(user.Registration)
-		c.config.SetAcmeDirectory(c.directory) // persist directory URL to compare registration
-	} else {
-		// validate registration
-		reg, err := client.Registration.QueryRegistration()
+	config := db.NewConfigDatabase(database)
+	for _, opts := range issuers {
+		// check if user is initialized (if not already done)
+		iss, err := mgr.register(config.ForIssuer(opts.Name), opts, email, false)
 		if err != nil {
-			c.logger.Errorf("Failed to query current ACME registration: %v", err)
-			c.config.ClearAcme() // clear ACME as it is no longer valid.
-			if !isRetry {
-				// retry client initialization
-				return c.init(email, true)
-			}
+			mgr.logger.Errorf("Failed to initialize ACME issuer=%s: %v", opts.Name, err)
+			continue
 		}
 
-		c.logger.Tracef("Current active ACME registration on uri=%s", reg.URI)
+		mgr.issuers = append(mgr.issuers, iss)
 	}
 
-	// set challenge providers
-	err = client.Challenge.SetHTTP01Provider(c)
-	if err != nil {
-		c.logger.Fatalf("Failed to register HTTP-01 provider: %v", err)
+	if len(mgr.issuers) == 0 {
+		mgr.logger.Errorf("No ACME issuer available, certificates can not be requested")
 	}
 
-	return client
+	return mgr
 }
 
 func (c *CertificateManager) Present(domain string, token string, auth string) error {
@@ -164,10 +102,21 @@ func (c *CertificateManager) Authorize(domain string, token string) (string, err
 	return challenge.Auth, nil
 }
 
+// ChallengeCertificate will create the TLS-ALPN-01 challenge certificate for the domain.
+// It will return an error if no challenge is pending for the domain.
+func (c *CertificateManager) ChallengeCertificate(domain string) (*tls.Certificate, error) {
+	challenge := c.data.GetDomainChallenge(domain)
+	if challenge == nil {
+		return nil, errors.New("no challenge available")
+	}
+
+	return tlsalpn01.ChallengeCert(domain, challenge.Auth)
+}
+
 // HasValidCertificate will check if all the specified domains have a valid certificate that is not yet expired.
 func (c *CertificateManager) HasValidCertificate(domains []string) bool {
 	for _, domain := range domains {
-		cert := c.Get(domain)
+		cert := c.get(domain)
 		if cert == nil {
 			return false
 		}
@@ -186,72 +135,95 @@ func (c *CertificateManager) HasValidCertificate(domains []string) bool {
 }
 
 // ChallengeCreate will create a new challenge request for the ingress domain.
-func (c *CertificateManager) ChallengeCreate(domains []string, challenge types.ChallengeType) error {
-	if c.acme == nil {
-		c.logger.Errorf("Unable to request certificate, ACME email is not configured")
-		return errors.New("ACME email is not configured")
-	}
+// The key settings are completed with the defaults of the daemon, they are kept for renewing the certificate.
+func (c *CertificateManager) ChallengeCreate(domains []string, challenge types.ChallengeType, key types.KeyOptions) error {
+	return c.challengeCreate(domains, challenge, key, false)
+}
+
+// ChallengeCreateOnDemand will create a new challenge request for domains requested at their first TLS handshake.
+// The order and certificate are marked as on-demand, they are kept while the domains have no route.
+func (c *CertificateManager) ChallengeCreateOnDemand(domains []string, challenge types.ChallengeType) error {
+	return c.challengeCreate(domains, challenge, types.KeyOptions{}, true)
+}
 
+// challengeCreate will create a new challenge request, see [CertificateManager.ChallengeCreate].
+func (c *CertificateManager) challengeCreate(domains []string, challenge types.ChallengeType, key types.KeyOptions, onDemand bool) error {
 	if challenge == types.ChallengeTypeNone {
 		c.logger.Tracef("Ignoring challenge creation for domains=%v", domains)
 		return nil
 	}
 
-	if challenge != types.ChallengeTypeHTTP {
-		c.logger.Errorf("Challenge type=%s is currently not supported", challenge)
-		return errors.New("challenge type not supported")
+	if challenge == types.ChallengeTypeManual {
+		c.logger.Warningf("Certificate for domains=%v must be imported manually", domains)
+		return nil
+	}
+
+	if challenge == types.ChallengeTypeInternal {
+		_, err := c.IssueInternal(domains, key)
+		return err
 	}
 
-	req := certificate.ObtainRequest{
-		Domains: []string{},
-		Bundle:  true,
+	if len(c.activeIssuers()) == 0 {
+		c.logger.Errorf("Unable to request certificate, ACME email is not configured")
+		return errors.New("ACME email is not configured")
+	}
+
+	if len(c.issuersFor(challenge)) == 0 {
+		c.logger.Errorf("Challenge type=%s is not configured", challenge)
+		return fmt.Errorf("challenge type=%s is not configured", challenge)
+	}
+
+	for _, domain := range domains {
+		if types.IsWildcard(domain) && challenge != types.ChallengeTypeDNS {
+			c.logger.Errorf("Wildcard domain=%s requires challenge type=%s", domain, types.ChallengeTypeDNS)
+			return fmt.Errorf("wildcard domain=%s requires challenge type=%s", domain, types.ChallengeTypeDNS)
+		}
 	}
 
 	// validate that no ongoing domain challenges are being done
+	requested := make([]string, 0, len(domains))
 	for _, domain := range domains {
 		if c.data.GetDomainChallenge(domain) != nil {
 			c.logger.Infof("Challenge for domain=%s already exists, skipping", domain)
 		} else {
 			// append domain to obtain request
-			req.Domains = append(req.Domains, domain)
+			requested = append(requested, domain)
 		}
 	}
 
-	if len(req.Domains) == 0 {
+	if len(requested) == 0 {
 		c.logger.Warningf("All specified domain are already being challenge, no action required")
 		return nil
 	}
 
-	go func() {
-		c.logger.Infof("Requesting certificate bundle (domains=%s)", strings.Join(req.Domains, ","))
-		resource, err := c.acme.Certificate.Obtain(req)
-		if err != nil {
-			c.logger.Errorf("Failed to obtain certificates: %v (domains=%s)", err, strings.Join(req.Domains, ","))
-			return
-		}
-
-		c.logger.Infof("Successfully obtained certificate bundle (domains=%s, uri=%s)", strings.Join(req.Domains, ","), resource.CertURL)
-		cert := &types.Certificate{
-			ID:            uuid.NewString(),
-			Certificate:   base64.StdEncoding.EncodeToString(resource.Certificate),
-			Key:           base64.StdEncoding.EncodeToString(resource.PrivateKey),
-			ChallengeType: challenge,
-			Domains:       req.Domains,
-		}
-
-		// persist the certificate for each domain
-		err = c.data.SetCertificate(cert)
-		if err != nil {
-			c.logger.Errorf("Failed to save certificate for: %v", err)
-		}
-	}()
+	order := c.order(requested, challenge, c.keyOptions(key))
+	order.OnDemand = order.OnDemand || onDemand
+	if order.State == types.OrderStateFailed && time.Now().Before(order.NextRetry) {
+		c.logger.Warningf("Order for domains=%s is backing off after %d failed attempts, next retry at %s", order.Key(), order.Attempts, order.NextRetry.Format(time.RFC3339))
+		return nil
+	}
 
+	c.submit(order)
 	return nil
 }
 
 // Get will retrieve the active certificate for the given domain if available.
+// When the domain has no certificate of its own the certificate of the covering wildcard domain is returned.
 // If no certificate is available it will return nil.
 func (c *CertificateManager) Get(domain string) *types.Certificate {
+	if cert := c.get(domain); cert != nil {
+		return cert
+	}
+
+	if wildcard := types.WildcardOf(domain); wildcard != "" {
+		return c.get(wildcard)
+	}
+
+	return nil
+}
+
+// get will retrieve the certificate mapped to exactly the given domain.
+func (c *CertificateManager) get(domain string) *types.Certificate {
 	cert, err := c.data.GetCertificate(domain)
 	if err != nil {
 		if !errors.Is(err, db.ErrItemNotFound) {
@@ -270,10 +242,11 @@ func (c *CertificateManager) GetAll() []types.Certificate {
 }
 
 // Batch will actively run a batch job to clean up all certificates that are not referenced anymore.
-// It will also check if any certificates are up for renewal.
-func (c *CertificateManager) Batch() {
+// It will also check if any certificates are up for renewal, the certificates that could not be renewed or removed are returned as error.
+func (c *CertificateManager) Batch() error {
 	c.logger.Info("Starting batch job for certificate management")
 
+	var errs []error
 	certificates := c.GetAll()
 	for _, cert := range certificates {
 
@@ -282,13 +255,29 @@ func (c *CertificateManager) Batch() {
 			info, err := cert.Parse()
 			if err != nil {
 				c.logger.Errorf("Failed to parse certificate with id=%s: %v", cert.ID, err)
+				errs = append(errs, fmt.Errorf("id=%s: %w", cert.ID, err))
 				continue
 			}
 
-			if time.Now().Add(ExpiryCutOff).After(info.NotAfter) {
-				c.logger.Warningf("Certificate with id=%s will expiry within 30 days, renewing (expiry=%s)", cert.ID, info.NotAfter.String())
-				if err = c.ChallengeCreate(cert.Domains, cert.ChallengeType); err != nil {
+			if cert.ChallengeType == types.ChallengeTypeManual {
+				// imported certificates can only be renewed by the user
+				if time.Now().Add(ExpiryCutOff).After(info.NotAfter) {
+					c.logger.Warningf("Imported certificate with id=%s will expire within 30 days, import a new certificate (domains=%s, expiry=%s)", cert.ID, strings.Join(cert.Domains, ","), info.NotAfter.String())
+					c.notify(types.NotificationExpiring, cert.Domains, cert.ID, "Imported certificate expires at %s, import a new certificate", info.NotAfter.Format(time.RFC3339))
+				}
+				continue
+			}
+
+			if time.Now().Add(ExpiryAlertCutOff).After(info.NotAfter) {
+				// automatic renewal should have replaced the certificate by now
+				c.notify(types.NotificationExpiring, cert.Domains, cert.ID, "Certificate expires at %s and has not been renewed", info.NotAfter.Format(time.RFC3339))
+			}
+
+			if c.shouldRenew(cert, info, time.Now()) {
+				c.logger.Warningf("Certificate with id=%s is due for renewal, renewing (expiry=%s)", cert.ID, info.NotAfter.String())
+				if err = c.challengeCreate(cert.Domains, cert.ChallengeType, cert.KeyOptions(), cert.OnDemand); err != nil {
 					c.logger.Errorf("Failed to create challenge for certificate with id=%s: %v", cert.ID, err)
+					errs = append(errs, fmt.Errorf("id=%s: %w", cert.ID, err))
 				} else {
 					c.logger.Infof("Successfully submitted challenge for renewing certificate with id=%s", cert.ID)
 				}
@@ -299,12 +288,56 @@ func (c *CertificateManager) Batch() {
 		} else {
 			// remove unused certificates from the system
 			c.logger.Infof("Certificate with id=%s is no longer in use, removing from system (domains=%s)", cert.ID, cert.Domains)
-			err := c.data.RemoveCertificateById(cert.ID)
+			err := c.data.RemoveCertificate(&cert)
 			if err != nil {
 				c.logger.Errorf("Failed to remove unused certificate with id=%s: %v", cert.ID, err)
+				errs = append(errs, fmt.Errorf("id=%s: %w", cert.ID, err))
 			}
 		}
 	}
 
 	c.logger.Info("Batch job for certificate management finished")
+	return errors.Join(errs...)
+}
+
+// CleanupChallenges will remove all pending ACME challenges older than the maximum age.
+// Challenges without a creation time were created by an older version and are always removed.
+func (c *CertificateManager) CleanupChallenges(maxAge time.Duration) error {
+	var errs []error
+	for domain, challenge := range c.data.GetAllAcmeChallenges() {
+		if !challenge.CreatedAt.IsZero() && time.Since(challenge.CreatedAt) < maxAge {
+			continue
+		}
+
+		c.logger.Infof("Removing stale ACME challenge for domain=%s (token=%s)", domain, challenge.Token)
+		if err := c.data.RemoveAcmeChallenge(domain, challenge.Token, challenge.Auth); err != nil {
+			errs = append(errs, fmt.Errorf("domain=%s: %w", domain, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RotateEncryptionKey will re-encrypt all stored private keys with a newly generated data key.
+// It returns the ID of the new data key.
+func (c *CertificateManager) RotateEncryptionKey() (string, error) {
+	return c.data.RotateEncryptionKey()
+}
+
+// notify will send the notification if notifications are configured.
+func (c *CertificateManager) notify(event types.NotificationEvent, domains []string, id string, format string, args ...any) {
+	if c.Notifications == nil {
+		return
+	}
+
+	notification := &types.Notification{
+		Event:         event,
+		Domains:       domains,
+		CertificateID: id,
+		Message:       fmt.Sprintf(format, args...),
+	}
+
+	if err := c.Notifications.Notify(context.Background(), notification); err != nil {
+		c.logger.Errorf("Failed to send notification for event=%s: %v", event, err)
+	}
 }
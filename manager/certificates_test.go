@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+func TestCertificateManager_Batch(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	route := &types.Ingress{Domains: []string{"www.example.com"}, TargetProject: "default", TargetService: "web", ChallengeType: types.ChallengeTypeHTTP}
+	if err := database.SaveIngressRoute(route); err != nil {
+		t.Fatalf("Failed to save route: %v", err)
+	}
+
+	cert, key := createTestCertificate(t, time.Now().Add(90*24*time.Hour), "www.example.com")
+	stored := &types.Certificate{
+		ID:            "cert",
+		Domains:       []string{"www.example.com"},
+		Certificate:   base64.StdEncoding.EncodeToString([]byte(cert)),
+		Key:           base64.StdEncoding.EncodeToString([]byte(key)),
+		ChallengeType: types.ChallengeTypeHTTP,
+	}
+	if err := database.SetCertificate(stored); err != nil {
+		t.Fatalf("Failed to save certificate: %v", err)
+	}
+
+	{
+		// valid certificates are not renewed
+		if err := mgr.Batch(); err != nil {
+			t.Errorf("Expected batch to succeed: %v", err)
+		}
+	}
+
+	{
+		// failed renewals are reported, no issuer is configured
+		cert, key = createTestCertificate(t, time.Now().Add(24*time.Hour), "www.example.com")
+		stored.Certificate = base64.StdEncoding.EncodeToString([]byte(cert))
+		stored.Key = base64.StdEncoding.EncodeToString([]byte(key))
+		if err := database.SetCertificate(stored); err != nil {
+			t.Fatalf("Failed to save certificate: %v", err)
+		}
+
+		if err := mgr.Batch(); err == nil {
+			t.Errorf("Expected failed renewal to be returned")
+		}
+	}
+}
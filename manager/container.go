@@ -10,11 +10,24 @@ import (
 	"github.com/jorenkoyen/conter/manager/types"
 	"github.com/jorenkoyen/go-logger"
 	"github.com/jorenkoyen/go-logger/log"
+	"net/http"
+	"slices"
 	"strings"
+	"time"
 )
 
+// ReplacementSuffix is appended to the container name while a replacement container is being started.
+const ReplacementSuffix = ".next"
+
+// OutdatedSuffix is appended to the container name of an outdated container while its replacement is promoted.
+const OutdatedSuffix = ".old"
+
+// ReadyTimeout is the maximum duration a replacement container gets to become ready before the deploy is aborted.
+var ReadyTimeout = time.Minute
+
 type Container struct {
 	logger         *logger.Logger
+	client         *http.Client
 	Database       *db.Client
 	Docker         *docker.Client
 	IngressManager *IngressManager
@@ -23,6 +36,7 @@ type Container struct {
 func NewContainerManager() *Container {
 	return &Container{
 		logger: log.WithName("container-mgr"),
+		client: new(http.Client),
 	}
 }
 
@@ -32,6 +46,27 @@ func (o *Container) DoesProjectExist(name string) bool {
 	return len(services) > 0
 }
 
+// WildcardChallengeTypes are the challenge types that can be used for exposing a service on a wildcard domain.
+var WildcardChallengeTypes = []types.ChallengeType{
+	types.ChallengeTypeDNS,
+	types.ChallengeTypeNone,
+	types.ChallengeTypeManual,
+	types.ChallengeTypeInternal,
+}
+
+// SupportedChallengeTypes are the challenge types that can be used for exposing a service.
+var SupportedChallengeTypes = []types.ChallengeType{
+	types.ChallengeTypeHTTP,
+	types.ChallengeTypeDNS,
+	types.ChallengeTypeTLS,
+	types.ChallengeTypeNone,
+	types.ChallengeTypeManual,
+	types.ChallengeTypeInternal,
+}
+
+// MaxReplicas is the maximum amount of containers that can be started for a single service.
+const MaxReplicas = 16
+
 type ApplyProjectOptions struct {
 	ProjectName string `json:"project_name"`
 	Services    []struct {
@@ -39,15 +74,25 @@ type ApplyProjectOptions struct {
 		Source         types.Source        `json:"source"`
 		Environment    map[string]string   `json:"environment"`
 		IngressDomains []string            `json:"ingress_domains"`
+		IngressPath    string              `json:"ingress_path"`
+		StripPrefix    bool                `json:"strip_prefix"`
+		HealthCheck    *types.HealthCheck  `json:"ingress_health_check"`
 		ContainerPort  int                 `json:"container_port"`
 		Volumes        []types.Volume      `json:"volumes"`
 		ChallengeType  types.ChallengeType `json:"challenge_type"`
+		KeyType        types.KeyType       `json:"key_type"`
+		ReuseKey       bool                `json:"reuse_key"`
 		Quota          types.Quota         `json:"quota"`
+		Replicas       int                 `json:"replicas"`
+		LoadBalancer   types.LoadBalancer  `json:"load_balancer"`
+
+		ContainerHealthCheck *types.ContainerHealthCheck `json:"healthcheck"`
 	} `json:"services"`
 }
 
 // validate will perform the basic validation required for applying a project configuration.
-func (opts *ApplyProjectOptions) validate() *types.ValidationError {
+// The routes are the ingress routes currently known to the system, they are used to detect overlapping rules.
+func (opts *ApplyProjectOptions) validate(routes []types.Ingress) *types.ValidationError {
 	err := new(types.ValidationError)
 
 	if opts.ProjectName == "" {
@@ -79,12 +124,83 @@ func (opts *ApplyProjectOptions) validate() *types.ValidationError {
 
 		if len(service.IngressDomains) > 0 {
 			// indication that service should be exposed
-			if service.ChallengeType != types.ChallengeTypeHTTP && service.ChallengeType != types.ChallengeTypeNone {
+			if !slices.Contains(SupportedChallengeTypes, service.ChallengeType) {
 				err.Appendf(prefix+"challenge_type", "Challenge type=%s is not supported", service.ChallengeType)
 			}
+			if service.KeyType != "" && !slices.Contains(SupportedKeyTypes, service.KeyType) {
+				err.Appendf(prefix+"key_type", "Key type=%s is not supported", service.KeyType)
+			}
 			if service.ContainerPort <= 0 {
 				err.Append(prefix+"container_port", "A valid container port is required to expose a service")
 			}
+			if strings.ContainsAny(service.IngressPath, " ?#") {
+				err.Append(prefix+"ingress_path", "Ingress path must not contain spaces, queries or fragments")
+			}
+
+			if check := service.HealthCheck; check != nil {
+				if !strings.HasPrefix(check.Path, "/") {
+					err.Append(prefix+"ingress_health_check.path", "Health check path must be absolute")
+				}
+				if check.Interval < 0 || check.Timeout < 0 || check.HealthyThreshold < 0 || check.UnhealthyThreshold < 0 {
+					err.Append(prefix+"ingress_health_check", "Health check values must not be negative")
+				}
+				if check.TimeoutDuration() > check.IntervalDuration() {
+					err.Append(prefix+"ingress_health_check.timeout", "Health check timeout must not exceed the interval")
+				}
+			}
+
+			path := types.NormalizePath(service.IngressPath)
+			for _, domain := range service.IngressDomains {
+				if strings.Contains(strings.TrimPrefix(domain, "*."), "*") || (types.IsWildcard(domain) && strings.Count(domain, ".") < 2) {
+					err.Appendf(prefix+"ingress_domains", "Domain=%s is not a valid wildcard domain, only a single leading '*.' label is supported", domain)
+				} else if types.IsWildcard(domain) && !slices.Contains(WildcardChallengeTypes, service.ChallengeType) {
+					err.Appendf(prefix+"challenge_type", "Wildcard domain=%s requires challenge type=%s", domain, types.ChallengeTypeDNS)
+				}
+
+				// check rules of other services within the project
+				for j, other := range opts.Services[:i] {
+					if slices.Contains(other.IngressDomains, domain) && types.NormalizePath(other.IngressPath) == path {
+						err.Appendf(prefix+"ingress_path", "Domain=%s with path=%s is already used by services[%d]", domain, path, j)
+					}
+				}
+
+				// check rules of other projects, different paths are routed by the longest matching prefix
+				for _, route := range routes {
+					if route.TargetProject == opts.ProjectName || !slices.ContainsFunc(route.Domains, func(d string) bool { return types.DomainsOverlap(d, domain) }) {
+						continue
+					}
+
+					if route.PathPrefix() == path {
+						err.Appendf(prefix+"ingress_domains", "Domain=%s with path=%s is already used by project=%s", domain, path, route.TargetProject)
+					}
+				}
+			}
+		}
+
+		if service.Replicas < 0 || service.Replicas > MaxReplicas {
+			err.Appendf(prefix+"replicas", "Replicas must be between 0 and %d", MaxReplicas)
+		}
+
+		if service.LoadBalancer != "" && service.LoadBalancer != types.LoadBalancerRoundRobin && service.LoadBalancer != types.LoadBalancerLeastConnections {
+			err.Appendf(prefix+"load_balancer", "Load balancer=%s is not supported", service.LoadBalancer)
+		}
+
+		if check := service.ContainerHealthCheck; check != nil {
+			if (len(check.Command) > 0) == (check.Path != "") {
+				err.Append(prefix+"healthcheck", "Health check requires either a command or a path")
+			}
+			if check.Path != "" && !strings.HasPrefix(check.Path, "/") {
+				err.Append(prefix+"healthcheck.path", "Health check path must be absolute")
+			}
+			if check.Path != "" && service.ContainerPort <= 0 {
+				err.Append(prefix+"healthcheck.path", "A valid container port is required for an HTTP health check")
+			}
+			if check.Interval < 0 || check.Timeout < 0 || check.Retries < 0 || check.StartPeriod < 0 {
+				err.Append(prefix+"healthcheck", "Health check values must not be negative")
+			}
+			if check.TimeoutDuration() > check.IntervalDuration() {
+				err.Append(prefix+"healthcheck.timeout", "Health check timeout must not exceed the interval")
+			}
 		}
 
 		if service.Quota.MemoryLimit > 0 {
@@ -128,12 +244,12 @@ func (opts *ApplyProjectOptions) validate() *types.ValidationError {
 // ApplyProject will apply the configuration changes for the specified project.
 // It will create the required resources and clean up the no longer referenced resources.
 func (o *Container) ApplyProject(ctx context.Context, opts *ApplyProjectOptions) ([]types.Service, error) {
-	if err := opts.validate(); err != nil {
+	if err := opts.validate(o.IngressManager.GetAllRoutes()); err != nil {
 		return nil, err
 	}
 
 	// translate apply options to requested services
-	domains := make([]string, 0, len(opts.Services))
+	routes := make([]string, 0, len(opts.Services))
 	containers := make([]string, 0, len(opts.Services))
 	services := make([]types.Service, len(opts.Services))
 	for i, service := range opts.Services {
@@ -142,27 +258,35 @@ func (o *Container) ApplyProject(ctx context.Context, opts *ApplyProjectOptions)
 			Hash:           "", // calculated below
 			ContainerName:  fmt.Sprintf("%s_%s", opts.ProjectName, service.Name),
 			ContainerImage: "", // retrieved below
+			Replicas:       service.Replicas,
 			Source:         service.Source,
 			Environment:    service.Environment,
 			Quota:          service.Quota,
 			Volumes:        service.Volumes,
+			HealthCheck:    service.ContainerHealthCheck,
 			Ingress: types.Ingress{
-				Domains:        service.IngressDomains,
-				ContainerPort:  service.ContainerPort,
-				TargetEndpoint: "", // will be supplied by docker (if exposed)
-				TargetService:  service.Name,
-				TargetProject:  opts.ProjectName,
-				ChallengeType:  service.ChallengeType,
+				Domains:         service.IngressDomains,
+				Path:            types.NormalizePath(service.IngressPath),
+				StripPrefix:     service.StripPrefix,
+				ContainerPort:   service.ContainerPort,
+				TargetEndpoints: nil, // will be supplied by docker (if exposed)
+				TargetService:   service.Name,
+				TargetProject:   opts.ProjectName,
+				LoadBalancer:    service.LoadBalancer,
+				HealthCheck:     service.HealthCheck,
+				ChallengeType:   service.ChallengeType,
+				KeyType:         service.KeyType,
+				ReuseKey:        service.ReuseKey,
 			},
 		}
 
-		// append domains for project
+		// append routes for project
 		if len(service.IngressDomains) > 0 {
-			domains = append(domains, service.IngressDomains...)
+			routes = append(routes, services[i].Ingress.RouteKeys()...)
 		}
 
-		// append container names
-		containers = append(containers, services[i].ContainerName)
+		// append container names (one for each replica)
+		containers = append(containers, services[i].ContainerNames()...)
 
 		// build or set container image
 		img, err := source.GetImageFromSource(ctx, services[i])
@@ -189,7 +313,7 @@ func (o *Container) ApplyProject(ctx context.Context, opts *ApplyProjectOptions)
 		return nil, fmt.Errorf("failed to create docker network: %w", err)
 	}
 
-	removed, err := o.IngressManager.RemoveUnusedRoutes(opts.ProjectName, domains)
+	removed, err := o.IngressManager.RemoveUnusedRoutes(opts.ProjectName, routes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to remove unused routes: %w", err)
 	}
@@ -221,52 +345,155 @@ func (o *Container) ApplyProject(ctx context.Context, opts *ApplyProjectOptions)
 }
 
 // ApplyService will create the resources required for starting the service.
+// A container is created for each replica and the ingress route is registered to all of their endpoints.
+//
+// Containers with an outdated configuration are replaced using a blue/green flow: the new container is started
+// next to the old one and the route is only switched once it is ready. If any new container does not become ready
+// the deploy is aborted and the old containers remain in service.
 func (o *Container) ApplyService(ctx context.Context, service types.Service, net *docker.Network) (*types.Service, error) {
+	// 1. create + start container for each replica (next to outdated containers)
+	// 2. register ingress route to all container endpoints
+	// 3. remove outdated containers and promote their replacements
+	endpoints := make([]string, 0, service.ReplicaCount())
+	replacements := make([]*replacement, 0)
+	for _, name := range service.ContainerNames() {
+		container, replaced, err := o.applyContainer(ctx, name, service, net)
+		if err != nil {
+			o.rollback(ctx, replacements)
+			return nil, err
+		}
+
+		if replaced != nil {
+			replacements = append(replacements, &replacement{name: name, current: replaced, next: container})
+		}
+
+		if container.Endpoint != "" {
+			endpoints = append(endpoints, container.Endpoint)
+		}
+	}
+
+	// service is configured to be exposed
+	service.Ingress.TargetEndpoints = endpoints
+	err := o.IngressManager.RegisterRoute(service.Ingress)
+	if err != nil {
+		o.rollback(ctx, replacements)
+		return nil, fmt.Errorf("failed to register ingress route: %w", err)
+	}
+
+	// traffic has been switched, the replacements take over the name of the outdated containers
+	for _, r := range replacements {
+		if err = o.promote(ctx, r); err != nil {
+			return nil, err
+		}
+
+		o.logger.Infof("Replaced container=%s with container=%s for service=%s", r.current.ID, r.next.ID, service.Name)
+	}
+
+	return &service, nil
+}
+
+// replacement keeps track of a container that is being replaced during a blue/green deploy.
+type replacement struct {
+	name    string
+	current *docker.Container
+	next    *docker.Container
+}
+
+// promote will give the replacement container the final name and remove the outdated container.
+// The outdated container is moved aside first, it gets its name back when the replacement can not be renamed.
+func (o *Container) promote(ctx context.Context, r *replacement) error {
+	if err := o.Docker.RenameContainer(ctx, r.current.ID, r.name+OutdatedSuffix); err != nil {
+		return fmt.Errorf("failed to rename old container: %w", err)
+	}
+
+	if err := o.Docker.RenameContainer(ctx, r.next.ID, r.name); err != nil {
+		if restoreErr := o.Docker.RenameContainer(ctx, r.current.ID, r.name); restoreErr != nil {
+			o.logger.Errorf("Failed to restore name of container=%s: %v", r.current.ID, restoreErr)
+		}
+		return fmt.Errorf("failed to rename container: %w", err)
+	}
+
+	if err := o.Docker.RemoveContainer(ctx, r.current.ID); err != nil {
+		// the outdated container no longer uses a name of the service, it is removed by the next apply
+		o.logger.Errorf("Failed to remove old container=%s: %v", r.current.ID, err)
+	}
+
+	return nil
+}
+
+// rollback will remove the replacement containers that have been created, the current containers stay in service.
+func (o *Container) rollback(ctx context.Context, replacements []*replacement) {
+	for _, r := range replacements {
+		o.logger.Warningf("Rolling back replacement container=%s, keeping container=%s in service", r.next.ID, r.current.ID)
+		if err := o.Docker.RemoveContainer(ctx, r.next.ID); err != nil {
+			o.logger.Errorf("Failed to remove replacement container=%s: %v", r.next.ID, err)
+		}
+	}
+}
+
+// applyContainer will make sure the container with the given name is running the latest configuration of the service.
+// When an existing container is outdated a replacement is started under a temporary name and the outdated container is
+// returned as well, it is up to the caller to remove it once the traffic has been switched.
+func (o *Container) applyContainer(ctx context.Context, name string, service types.Service, net *docker.Network) (*docker.Container, *docker.Container, error) {
 	// PRE. check if container already exists
-	// 	-> compare hash (remove container if it's different)
+	// 	-> compare hash (start replacement if it's different)
 	//	-> start container if it's not running
 	//	-> no action required, container already exists
-	container := o.Docker.FindContainer(ctx, service.ContainerName)
+	container := o.Docker.FindContainer(ctx, name)
 	if container != nil {
-		o.logger.Debugf("Services with name=%s already exists, checking status (container_id=%s)", service.Name, container.ID)
-
-		// we should remap the endpoint here.
-		// docker decides what endpoint the container is exposed on
-		service.Ingress.TargetEndpoint = container.Endpoint
+		o.logger.Debugf("Container with name=%s for service=%s already exists, checking status (container_id=%s)", name, service.Name, container.ID)
 
-		if container.ConfigHash != service.Hash {
-			o.logger.Warningf("Configuration hash does not match for service=%s with container_id=%s, rebuilding", service.Name, container.ID)
-			err := o.Docker.RemoveContainer(ctx, container.ID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to remove old container: %w", err)
-			}
-
-			// reset target endpoint -> we have removed it.
-			service.Ingress.TargetEndpoint = ""
-		} else {
+		if container.ConfigHash == service.Hash {
 			if container.IsRunning() {
-				o.logger.Tracef("Services with name=%s is already running, no action required", service.Name)
+				o.logger.Tracef("Container with name=%s is already running, no action required", name)
 			} else {
 				o.logger.Warningf("Container with id=%s for service=%s is not running, restarting", container.ID, service.Name)
 				err := o.Docker.StartContainer(ctx, container.ID)
 				if err != nil {
-					return nil, fmt.Errorf("unable to start container: %w", err)
+					return nil, nil, fmt.Errorf("unable to start container: %w", err)
 				}
 			}
 
-			// make sure that routes are registered
-			err := o.IngressManager.RegisterRoute(service.Ingress)
-			if err != nil {
-				return nil, fmt.Errorf("failed to register routes: %w", err)
+			// docker decides what endpoint the container is exposed on
+			return container, nil, nil
+		}
+
+		o.logger.Warningf("Configuration hash does not match for service=%s with container_id=%s, starting replacement", service.Name, container.ID)
+		next, err := o.startContainer(ctx, name+ReplacementSuffix, service, net)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err = o.waitUntilReady(ctx, next, service); err != nil {
+			o.logger.Errorf("Replacement container=%s for service=%s did not become ready: %v", next.ID, service.Name, err)
+			if rmErr := o.Docker.RemoveContainer(ctx, next.ID); rmErr != nil {
+				o.logger.Errorf("Failed to remove replacement container=%s: %v", next.ID, rmErr)
 			}
+			return nil, nil, fmt.Errorf("replacement container did not become ready: %w", err)
+		}
+
+		return next, container, nil
+	}
+
+	container, err := o.startContainer(ctx, name, service, net)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return container, nil, nil
+}
 
-			return &service, nil
+// startContainer will create and start a new container for the service.
+// Any lingering container with the same name will be removed first.
+func (o *Container) startContainer(ctx context.Context, name string, service types.Service, net *docker.Network) (*docker.Container, error) {
+	if existing := o.Docker.FindContainer(ctx, name); existing != nil {
+		o.logger.Debugf("Removing lingering container=%s with name=%s", existing.ID, name)
+		if err := o.Docker.RemoveContainer(ctx, existing.ID); err != nil {
+			return nil, fmt.Errorf("failed to remove lingering container: %w", err)
 		}
 	}
 
-	// 1. create + start container from service
-	// 2. register ingress route to container endpoint
-	container, err := o.Docker.CreateContainer(ctx, service, net)
+	container, err := o.Docker.CreateContainer(ctx, name, service, net)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create container: %w", err)
 	}
@@ -276,15 +503,58 @@ func (o *Container) ApplyService(ctx context.Context, service types.Service, net
 		return nil, fmt.Errorf("failed to start container: %w", err)
 	}
 
-	// service is configured to be exposed
-	service.Ingress.TargetEndpoint = container.Endpoint
-	err = o.IngressManager.RegisterRoute(service.Ingress)
-	if err != nil {
-		return nil, fmt.Errorf("failed to register ingress route: %w", err)
+	o.logger.Debugf("Successfully created container=%s for service=%s (project=%s)", container.ID, service.Name, service.Ingress.TargetProject)
+	return container, nil
+}
+
+// waitUntilReady will block until the container is running and passes the health checks of the service (if configured).
+// A container with a docker health check must be reported healthy, it fails immediately once reported unhealthy.
+// It will give up after [ReadyTimeout], or once the docker health check had the time to mark the container unhealthy.
+func (o *Container) waitUntilReady(ctx context.Context, container *docker.Container, service types.Service) error {
+	timeout := ReadyTimeout
+	if check := service.HealthCheck; check != nil {
+		timeout = max(timeout, check.StartPeriodDuration()+check.IntervalDuration()*time.Duration(check.RetryCount()+1))
 	}
 
-	o.logger.Debugf("Successfully created container=%s for service=%s (project=%s)", container.ID, service.Name, service.Ingress.TargetProject)
-	return &service, nil
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second / 2)
+	defer ticker.Stop()
+
+	lastErr := errors.New("container is not running")
+	for {
+		current := o.Docker.FindContainer(ctx, container.ID)
+		if current != nil && current.IsRunning() {
+			lastErr = o.isReady(ctx, current, container.Endpoint, service)
+			if lastErr == nil {
+				return nil
+			}
+
+			if current.Health == StatusUnhealthy {
+				return lastErr
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return lastErr
+		case <-ticker.C:
+		}
+	}
+}
+
+// isReady will return an error if the running container does not pass the health checks of the service.
+func (o *Container) isReady(ctx context.Context, container *docker.Container, endpoint string, service types.Service) error {
+	if service.HealthCheck != nil && container.Health != StatusHealthy {
+		return fmt.Errorf("container health is %q", container.Health)
+	}
+
+	if check := service.Ingress.HealthCheck; check != nil && endpoint != "" {
+		return check.Probe(ctx, o.client, endpoint)
+	}
+
+	return nil
 }
 
 // RemoveProject will remove the resources associated to the project.
@@ -319,11 +589,22 @@ const (
 	StatusNotAvailable = "not_available"
 	StatusRunning      = "running"
 	StatusStopped      = "stopped"
+
+	// statuses of running services with a health check
+	StatusStarting  = "starting"
+	StatusHealthy   = "healthy"
+	StatusUnhealthy = "unhealthy"
 )
 
+// IsRunningStatus will return true if all containers of the service are running, regardless of their health.
+func IsRunningStatus(status string) bool {
+	return status == StatusRunning || status == StatusStarting || status == StatusHealthy || status == StatusUnhealthy
+}
+
 type Status struct {
-	Services []types.Service
-	statuses map[string]string
+	Services   []types.Service
+	statuses   map[string]string
+	containers map[string][]docker.Container
 }
 
 // GetState retrieves the current state of the service.
@@ -336,10 +617,16 @@ func (s *Status) GetState(service string) string {
 	return current
 }
 
+// GetContainers retrieves the containers of the service that are available on the system.
+func (s *Status) GetContainers(service string) []docker.Container {
+	return s.containers[service]
+}
+
 // GetProjectStatus will return the actual status of the container running on the system.
 func (o *Container) GetProjectStatus(ctx context.Context, project string) (*Status, error) {
 	status := &Status{
-		statuses: make(map[string]string),
+		statuses:   make(map[string]string),
+		containers: make(map[string][]docker.Container),
 	}
 
 	status.Services = o.Database.GetServicesForProject(project)
@@ -347,21 +634,53 @@ func (o *Container) GetProjectStatus(ctx context.Context, project string) (*Stat
 		return nil, errors.New("no services found")
 	}
 
-	// go over each service and inspect both ingress + container
+	// go over each service and inspect the container of every replica
 	for _, service := range status.Services {
-		container := o.Docker.FindContainer(ctx, service.ContainerName)
-		if container != nil {
+		found, running := 0, 0
+		health := make(map[string]int)
+		for _, name := range service.ContainerNames() {
+			container := o.Docker.FindContainer(ctx, name)
+			if container == nil {
+				continue
+			}
+
+			found++
+			status.containers[service.Name] = append(status.containers[service.Name], *container)
 			if container.IsRunning() {
-				status.statuses[service.Name] = StatusRunning
-			} else {
-				status.statuses[service.Name] = StatusStopped
+				running++
+				health[container.Health]++
 			}
 		}
+
+		if found == 0 {
+			continue // not available
+		}
+
+		if running != service.ReplicaCount() {
+			status.statuses[service.Name] = StatusStopped
+		} else {
+			status.statuses[service.Name] = serviceHealth(service, health)
+		}
 	}
 
 	return status, nil
 }
 
+// serviceHealth will return the status of a running service based on the health of its containers.
+// A single unhealthy container marks the service as unhealthy, it is only healthy once all containers are.
+func serviceHealth(service types.Service, health map[string]int) string {
+	switch {
+	case service.HealthCheck == nil:
+		return StatusRunning
+	case health[StatusUnhealthy] > 0:
+		return StatusUnhealthy
+	case health[StatusHealthy] == service.ReplicaCount():
+		return StatusHealthy
+	default:
+		return StatusStarting
+	}
+}
+
 // IsProjectRunning will return true if all services within the project are running.
 func (o *Container) IsProjectRunning(ctx context.Context, project string) bool {
 	status, err := o.GetProjectStatus(ctx, project)
@@ -370,7 +689,7 @@ func (o *Container) IsProjectRunning(ctx context.Context, project string) bool {
 	}
 
 	for _, service := range status.Services {
-		if status.GetState(service.Name) != StatusRunning {
+		if !IsRunningStatus(status.GetState(service.Name)) {
 			return false
 		}
 	}
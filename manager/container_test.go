@@ -13,14 +13,30 @@ func createEmptyApplyProjectOptions() *ApplyProjectOptions {
 		Source         types.Source        `json:"source"`
 		Environment    map[string]string   `json:"environment"`
 		IngressDomains []string            `json:"ingress_domains"`
+		IngressPath    string              `json:"ingress_path"`
+		StripPrefix    bool                `json:"strip_prefix"`
+		HealthCheck    *types.HealthCheck  `json:"ingress_health_check"`
 		ContainerPort  int                 `json:"container_port"`
 		Volumes        []types.Volume      `json:"volumes"`
 		ChallengeType  types.ChallengeType `json:"challenge_type"`
+		KeyType        types.KeyType       `json:"key_type"`
+		ReuseKey       bool                `json:"reuse_key"`
 		Quota          types.Quota         `json:"quota"`
+		Replicas       int                 `json:"replicas"`
+		LoadBalancer   types.LoadBalancer  `json:"load_balancer"`
+
+		ContainerHealthCheck *types.ContainerHealthCheck `json:"healthcheck"`
 	}, 1)
 	return opts
 }
 
+func AssertEquals(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if expected != actual {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+}
+
 func AssertNotEmpty(t *testing.T, val string, message string) {
 	t.Helper()
 	if val == "" {
@@ -46,7 +62,7 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts := createEmptyApplyProjectOptions()
 		opts.ProjectName = ""
 
-		err := opts.validate()
+		err := opts.validate(nil)
 		AssertErrorThrownForField(t, err, "project_name")
 	}
 
@@ -56,7 +72,7 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts.ProjectName = "default"
 		opts.Services = nil
 
-		err := opts.validate()
+		err := opts.validate(nil)
 		AssertErrorThrownForField(t, err, "services")
 	}
 
@@ -66,7 +82,7 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts.ProjectName = "default"
 		opts.Services[0].Name = ""
 
-		err := opts.validate()
+		err := opts.validate(nil)
 		AssertErrorThrownForField(t, err, "services[0].name")
 	}
 
@@ -76,7 +92,7 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts.ProjectName = "default"
 		opts.Services[0].Name = "www"
 
-		err := opts.validate()
+		err := opts.validate(nil)
 		AssertErrorThrownForField(t, err, "services[0].source.type")
 	}
 
@@ -87,7 +103,7 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts.Services[0].Name = "www"
 		opts.Services[0].Source.Type = "docker"
 
-		err := opts.validate()
+		err := opts.validate(nil)
 		AssertErrorThrownForField(t, err, "services[0].source.uri")
 	}
 
@@ -99,7 +115,7 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts.Services[0].Source.Type = "git"
 		opts.Services[0].Source.URI = "git@github.com/user/repository:master"
 
-		err := opts.validate()
+		err := opts.validate(nil)
 		AssertErrorThrownForField(t, err, "services[0].source.type")
 
 	}
@@ -112,7 +128,7 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts.Services[0].Source.Type = "other"
 		opts.Services[0].Source.URI = "../"
 
-		err := opts.validate()
+		err := opts.validate(nil)
 		AssertErrorThrownForField(t, err, "services[0].source.type")
 	}
 
@@ -126,12 +142,12 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts.Services[0].IngressDomains = []string{"www.localtest.me"}
 		opts.Services[0].ChallengeType = types.ChallengeTypeHTTP
 
-		err := opts.validate()
+		err := opts.validate(nil)
 		AssertErrorThrownForField(t, err, "services[0].container_port")
 	}
 
 	{
-		// challenge type 'dns' is not supported
+		// challenge type 'dns' is supported
 		opts := createEmptyApplyProjectOptions()
 		opts.ProjectName = "default"
 		opts.Services[0].Name = "www"
@@ -141,12 +157,13 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts.Services[0].ChallengeType = types.ChallengeTypeDNS
 		opts.Services[0].ContainerPort = 80
 
-		err := opts.validate()
-		AssertErrorThrownForField(t, err, "services[0].challenge_type")
+		if err := opts.validate(nil); err != nil {
+			t.Errorf("Expected challenge type=%s to be supported: %v", types.ChallengeTypeDNS, err)
+		}
 	}
 
 	{
-		// challenge type 'tls' is not supported
+		// challenge type 'tls' is supported
 		opts := createEmptyApplyProjectOptions()
 		opts.ProjectName = "default"
 		opts.Services[0].Name = "www"
@@ -156,8 +173,9 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts.Services[0].ChallengeType = types.ChallengeTypeTLS
 		opts.Services[0].ContainerPort = 80
 
-		err := opts.validate()
-		AssertErrorThrownForField(t, err, "services[0].challenge_type")
+		if err := opts.validate(nil); err != nil {
+			t.Errorf("Expected challenge type=%s to be supported: %v", types.ChallengeTypeTLS, err)
+		}
 	}
 
 	{
@@ -171,10 +189,65 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts.Services[0].ChallengeType = "other"
 		opts.Services[0].ContainerPort = 80
 
-		err := opts.validate()
+		err := opts.validate(nil)
 		AssertErrorThrownForField(t, err, "services[0].challenge_type")
 	}
 
+	{
+		// key type 'EC521' is not supported
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].IngressDomains = []string{"www.localtest.me"}
+		opts.Services[0].ChallengeType = types.ChallengeTypeHTTP
+		opts.Services[0].KeyType = "EC521"
+		opts.Services[0].ContainerPort = 80
+
+		err := opts.validate(nil)
+		AssertErrorThrownForField(t, err, "services[0].key_type")
+	}
+
+	{
+		// health check requires either a command or a path
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "db"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "postgres:latest"
+		opts.Services[0].ContainerHealthCheck = &types.ContainerHealthCheck{Command: []string{"pg_isready"}, Path: "/health"}
+
+		err := opts.validate(nil)
+		AssertErrorThrownForField(t, err, "services[0].healthcheck")
+	}
+
+	{
+		// HTTP health check requires a container port
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "worker"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].ContainerHealthCheck = &types.ContainerHealthCheck{Path: "/health"}
+
+		err := opts.validate(nil)
+		AssertErrorThrownForField(t, err, "services[0].healthcheck.path")
+	}
+
+	{
+		// health check timeout exceeds the interval
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "db"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "postgres:latest"
+		opts.Services[0].ContainerHealthCheck = &types.ContainerHealthCheck{Command: []string{"pg_isready"}, Interval: 5, Timeout: 10}
+
+		err := opts.validate(nil)
+		AssertErrorThrownForField(t, err, "services[0].healthcheck.timeout")
+	}
+
 	{
 		// quota is below 128MB
 		opts := createEmptyApplyProjectOptions()
@@ -187,7 +260,245 @@ func TestApplyProjectOptions_validate(t *testing.T) {
 		opts.Services[0].ContainerPort = 80
 		opts.Services[0].Quota.MemoryLimit = 64
 
-		err := opts.validate()
+		err := opts.validate(nil)
 		AssertErrorThrownForField(t, err, "services[0].quota.memory_limit")
 	}
+
+	{
+		// same domain and path used twice within the project
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services = append(opts.Services, opts.Services[0])
+		for i := range opts.Services {
+			opts.Services[i].Name = fmt.Sprintf("www%d", i)
+			opts.Services[i].Source.Type = "docker"
+			opts.Services[i].Source.URI = "nginx:latest"
+			opts.Services[i].IngressDomains = []string{"www.localtest.me"}
+			opts.Services[i].IngressPath = "/api"
+			opts.Services[i].ChallengeType = types.ChallengeTypeHTTP
+			opts.Services[i].ContainerPort = 80
+		}
+
+		err := opts.validate(nil)
+		AssertErrorThrownForField(t, err, "services[1].ingress_path")
+	}
+
+	{
+		// path is already used by a route of another project
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].IngressDomains = []string{"www.localtest.me"}
+		opts.Services[0].IngressPath = "api/"
+		opts.Services[0].ChallengeType = types.ChallengeTypeHTTP
+		opts.Services[0].ContainerPort = 80
+
+		routes := []types.Ingress{
+			{Domains: []string{"www.localtest.me"}, Path: "/api", TargetProject: "other"},
+		}
+
+		err := opts.validate(routes)
+		AssertErrorThrownForField(t, err, "services[0].ingress_domains")
+	}
+
+	{
+		// different paths of another project on the same domain are allowed
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].IngressDomains = []string{"www.localtest.me"}
+		opts.Services[0].IngressPath = "/api"
+		opts.Services[0].ChallengeType = types.ChallengeTypeHTTP
+		opts.Services[0].ContainerPort = 80
+
+		routes := []types.Ingress{
+			{Domains: []string{"www.localtest.me"}, Path: "/app", TargetProject: "other"},
+			{Domains: []string{"www.localtest.me"}, Path: "/", TargetProject: "default"},
+		}
+
+		if err := opts.validate(routes); err != nil {
+			t.Errorf("Expected no validation errors for non-overlapping paths: %v", err)
+		}
+	}
+
+	{
+		// nested paths of another project on the same domain are allowed
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].IngressDomains = []string{"www.localtest.me"}
+		opts.Services[0].IngressPath = "/api"
+		opts.Services[0].ChallengeType = types.ChallengeTypeHTTP
+		opts.Services[0].ContainerPort = 80
+
+		routes := []types.Ingress{
+			{Domains: []string{"www.localtest.me"}, Path: "/", TargetProject: "other"},
+		}
+
+		if err := opts.validate(routes); err != nil {
+			t.Errorf("Expected no validation errors for nested paths: %v", err)
+		}
+	}
+
+	{
+		// wildcard domain requires the DNS challenge
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].IngressDomains = []string{"*.customers.localtest.me"}
+		opts.Services[0].ChallengeType = types.ChallengeTypeHTTP
+		opts.Services[0].ContainerPort = 80
+
+		err := opts.validate(nil)
+		AssertErrorThrownForField(t, err, "services[0].challenge_type")
+	}
+
+	{
+		// wildcard is only supported as leading label
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].IngressDomains = []string{"www.*.localtest.me"}
+		opts.Services[0].ChallengeType = types.ChallengeTypeDNS
+		opts.Services[0].ContainerPort = 80
+
+		err := opts.validate(nil)
+		AssertErrorThrownForField(t, err, "services[0].ingress_domains")
+	}
+
+	{
+		// wildcard overlaps with a wildcard of another project
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].IngressDomains = []string{"*.customers.localtest.me"}
+		opts.Services[0].ChallengeType = types.ChallengeTypeDNS
+		opts.Services[0].ContainerPort = 80
+
+		routes := []types.Ingress{
+			{Domains: []string{"*.customers.localtest.me"}, Path: "/", TargetProject: "other"},
+		}
+
+		err := opts.validate(routes)
+		AssertErrorThrownForField(t, err, "services[0].ingress_domains")
+	}
+
+	{
+		// domain covered by a wildcard of another project
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].IngressDomains = []string{"acme.customers.localtest.me"}
+		opts.Services[0].ChallengeType = types.ChallengeTypeHTTP
+		opts.Services[0].ContainerPort = 80
+
+		routes := []types.Ingress{
+			{Domains: []string{"*.customers.localtest.me"}, Path: "/", TargetProject: "other"},
+		}
+
+		err := opts.validate(routes)
+		AssertErrorThrownForField(t, err, "services[0].ingress_domains")
+	}
+
+	{
+		// wildcard on a different level of another project is allowed
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].IngressDomains = []string{"*.customers.localtest.me"}
+		opts.Services[0].ChallengeType = types.ChallengeTypeDNS
+		opts.Services[0].ContainerPort = 80
+
+		routes := []types.Ingress{
+			{Domains: []string{"*.localtest.me"}, Path: "/", TargetProject: "other"},
+		}
+
+		if err := opts.validate(routes); err != nil {
+			t.Errorf("Expected no validation errors for wildcards on different levels: %v", err)
+		}
+	}
+
+	{
+		// too many replicas
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].Replicas = MaxReplicas + 1
+
+		err := opts.validate(nil)
+		AssertErrorThrownForField(t, err, "services[0].replicas")
+	}
+
+	{
+		// load balancer 'random' is not supported
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].LoadBalancer = "random"
+
+		err := opts.validate(nil)
+		AssertErrorThrownForField(t, err, "services[0].load_balancer")
+	}
+
+	{
+		// health check path must be absolute
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].IngressDomains = []string{"www.localtest.me"}
+		opts.Services[0].ChallengeType = types.ChallengeTypeHTTP
+		opts.Services[0].ContainerPort = 80
+		opts.Services[0].HealthCheck = &types.HealthCheck{Path: "health"}
+
+		err := opts.validate(nil)
+		AssertErrorThrownForField(t, err, "services[0].ingress_health_check.path")
+	}
+
+	{
+		// health check timeout exceeds interval
+		opts := createEmptyApplyProjectOptions()
+		opts.ProjectName = "default"
+		opts.Services[0].Name = "www"
+		opts.Services[0].Source.Type = "docker"
+		opts.Services[0].Source.URI = "nginx:latest"
+		opts.Services[0].IngressDomains = []string{"www.localtest.me"}
+		opts.Services[0].ChallengeType = types.ChallengeTypeHTTP
+		opts.Services[0].ContainerPort = 80
+		opts.Services[0].HealthCheck = &types.HealthCheck{Path: "/health", Interval: 5, Timeout: 10}
+
+		err := opts.validate(nil)
+		AssertErrorThrownForField(t, err, "services[0].ingress_health_check.timeout")
+	}
+}
+
+func TestServiceHealth(t *testing.T) {
+	service := types.Service{Replicas: 2}
+	AssertEquals(t, StatusRunning, serviceHealth(service, map[string]int{"": 2}))
+
+	service.HealthCheck = &types.ContainerHealthCheck{Command: []string{"true"}}
+	AssertEquals(t, StatusStarting, serviceHealth(service, map[string]int{StatusStarting: 1, StatusHealthy: 1}))
+	AssertEquals(t, StatusHealthy, serviceHealth(service, map[string]int{StatusHealthy: 2}))
+	AssertEquals(t, StatusUnhealthy, serviceHealth(service, map[string]int{StatusUnhealthy: 1, StatusHealthy: 1}))
 }
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"github.com/jorenkoyen/conter/manager/types"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/jorenkoyen/go-logger"
@@ -24,6 +26,9 @@ var (
 	BucketChallenges          = []byte("challenges")
 	BucketCertificates        = []byte("certificates")
 	BucketCertificateMappings = []byte("certificate-mappings")
+	BucketOrders              = []byte("orders")
+	BucketNotifications       = []byte("notifications")
+	BucketRenewals            = []byte("renewals")
 
 	ErrItemNotFound     = errors.New("item not found")
 	ErrCertificateInUse = errors.New("certificate in use")
@@ -33,6 +38,12 @@ var (
 type Client struct {
 	logger *logger.Logger
 	bolt   *bbolt.DB
+
+	routeListeners       []func()
+	certificateListeners []func()
+
+	keyringMutex sync.RWMutex
+	keyring      *keyring // nil when encryption is disabled
 }
 
 // NewClient will create a new database client for handling operations.
@@ -49,6 +60,32 @@ func NewClient(directory string) *Client {
 	return &Client{logger: l, bolt: db}
 }
 
+// OnRoutesChanged will register a listener that is called every time the ingress routes have been modified.
+// Listeners should be registered before the client is used concurrently.
+func (c *Client) OnRoutesChanged(listener func()) {
+	c.routeListeners = append(c.routeListeners, listener)
+}
+
+// notifyRoutesChanged will call all listeners interested in route changes.
+func (c *Client) notifyRoutesChanged() {
+	for _, listener := range c.routeListeners {
+		listener()
+	}
+}
+
+// OnCertificatesChanged will register a listener that is called every time a certificate has been stored or removed.
+// Listeners should be registered before the client is used concurrently.
+func (c *Client) OnCertificatesChanged(listener func()) {
+	c.certificateListeners = append(c.certificateListeners, listener)
+}
+
+// notifyCertificatesChanged will call all listeners interested in certificate changes.
+func (c *Client) notifyCertificatesChanged() {
+	for _, listener := range c.certificateListeners {
+		listener()
+	}
+}
+
 // SaveProject will persist the project in the database.
 func (c *Client) SaveProject(project string, services []types.Service) error {
 	return c.bolt.Update(func(tx *bbolt.Tx) error {
@@ -118,26 +155,38 @@ func (c *Client) GetAllProjects() map[string][]types.Service {
 	return output
 }
 
-// GetIngressRoute will return the ingress route if it exists.
-func (c *Client) GetIngressRoute(domain string) (*types.Ingress, error) {
-	route := new(types.Ingress)
-	err := c.bolt.View(func(tx *bbolt.Tx) error {
+// GetIngressRoutesByDomain will return all ingress routes registered for the domain, regardless of their path.
+func (c *Client) GetIngressRoutesByDomain(domain string) []types.Ingress {
+	output := make([]types.Ingress, 0)
+	_ = c.bolt.View(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(BucketRoutes)
 		if bucket == nil {
-			return ErrItemNotFound
+			return nil
 		}
 
-		content := bucket.Get([]byte(domain))
-		if content == nil {
-			return ErrItemNotFound
+		prefix := []byte(domain)
+		cursor := bucket.Cursor()
+		for key, content := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, content = cursor.Next() {
+			// only accept the bare domain or the domain followed by a path
+			if len(key) != len(prefix) && key[len(prefix)] != '/' {
+				continue
+			}
+
+			r := new(types.Ingress)
+			if err := json.Unmarshal(content, r); err == nil {
+				output = append(output, *r)
+			}
 		}
 
-		return json.Unmarshal(content, route)
+		return nil
 	})
-	return route, err
+
+	return output
 }
 
+// SaveIngressRoute will persist the ingress route for each domain in combination with the path prefix.
 func (c *Client) SaveIngressRoute(route *types.Ingress) error {
+	defer c.notifyRoutesChanged()
 	return c.bolt.Update(func(tx *bbolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists(BucketRoutes)
 		if err != nil {
@@ -150,8 +199,8 @@ func (c *Client) SaveIngressRoute(route *types.Ingress) error {
 		}
 
 		// save entry for each domain
-		for _, domain := range route.Domains {
-			if err = bucket.Put([]byte(domain), content); err != nil {
+		for _, key := range route.RouteKeys() {
+			if err = bucket.Put([]byte(key), content); err != nil {
 				return err
 			}
 		}
@@ -160,8 +209,8 @@ func (c *Client) SaveIngressRoute(route *types.Ingress) error {
 	})
 }
 
-// GetIngressRoutesByProject returns all ingress routes related to the project.
-func (c *Client) GetIngressRoutesByProject(project string) map[string]types.Ingress {
+// GetAllIngressRoutes returns all ingress routes known by the system with the key being the route key.
+func (c *Client) GetAllIngressRoutes() map[string]types.Ingress {
 	output := make(map[string]types.Ingress)
 	_ = c.bolt.View(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(BucketRoutes)
@@ -169,11 +218,10 @@ func (c *Client) GetIngressRoutesByProject(project string) map[string]types.Ingr
 			return nil
 		}
 
-		return bucket.ForEach(func(domain, content []byte) error {
+		return bucket.ForEach(func(key, content []byte) error {
 			r := new(types.Ingress)
-			if err := json.Unmarshal(content, r); err == nil && r.TargetProject == project {
-				// append to routes array
-				output[string(domain)] = *r
+			if err := json.Unmarshal(content, r); err == nil {
+				output[string(key)] = *r
 			}
 			return nil
 		})
@@ -182,15 +230,121 @@ func (c *Client) GetIngressRoutesByProject(project string) map[string]types.Ingr
 	return output
 }
 
-func (c *Client) RemoveIngressRoute(domain string) error {
-	return c.bolt.Update(func(tx *bbolt.Tx) error {
+// GetIngressRoutesByProject returns all ingress routes related to the project with the key being the route key.
+func (c *Client) GetIngressRoutesByProject(project string) map[string]types.Ingress {
+	output := make(map[string]types.Ingress)
+	for key, route := range c.GetAllIngressRoutes() {
+		if route.TargetProject == project {
+			output[key] = route
+		}
+	}
+
+	return output
+}
+
+// RemoveIngressRoute will remove the ingress route with the specified route key.
+// The certificate mapping of the domain is removed once no other route uses the domain.
+func (c *Client) RemoveIngressRoute(key string) error {
+	defer c.notifyRoutesChanged()
+
+	unmapped := false
+	err := c.bolt.Update(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(BucketRoutes)
 		if bucket == nil {
 			return nil
 		}
 
-		return bucket.Delete([]byte(domain))
+		if err := bucket.Delete([]byte(key)); err != nil {
+			return err
+		}
+
+		// the wildcard mapping could have been serving the removed domain as well
+		domain, _, _ := strings.Cut(key, "/")
+		var err error
+		unmapped, err = removeUnroutedMappings(tx, []string{domain, types.WildcardOf(domain)})
+		return err
 	})
+
+	if unmapped {
+		c.notifyCertificatesChanged()
+	}
+
+	return err
+}
+
+// IsDomainRouted will return true if a route is registered for the domain, either by its own route or by a wildcard.
+func (c *Client) IsDomainRouted(domain string) bool {
+	routed := false
+	_ = c.bolt.View(func(tx *bbolt.Tx) error {
+		routed = isDomainRouted(tx, domain)
+		return nil
+	})
+
+	return routed
+}
+
+// isDomainRouted will return true if a route is registered for the domain.
+// A wildcard domain is routed when it covers the domain of a route, a domain is routed when a wildcard route covers it.
+func isDomainRouted(tx *bbolt.Tx, domain string) bool {
+	bucket := tx.Bucket(BucketRoutes)
+	if bucket == nil {
+		return false
+	}
+
+	cursor := bucket.Cursor()
+	for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+		routed, _, _ := strings.Cut(string(key), "/")
+		if routed == domain || types.WildcardOf(routed) == domain || routed == types.WildcardOf(domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeUnroutedMappings will remove the certificate mappings of the domains no longer used by any route.
+// Mappings of retained certificates are kept. It will return true if any mapping has been removed.
+func removeUnroutedMappings(tx *bbolt.Tx, domains []string) (bool, error) {
+	bucket := tx.Bucket(BucketCertificateMappings)
+	if bucket == nil {
+		return false, nil
+	}
+
+	removed := false
+	for _, domain := range domains {
+		if domain == "" || isDomainRouted(tx, domain) {
+			continue
+		}
+
+		id := bucket.Get([]byte(domain))
+		if id == nil || isCertificateRetained(tx, id) {
+			continue
+		}
+
+		if err := bucket.Delete([]byte(domain)); err != nil {
+			return removed, err
+		}
+
+		removed = true
+	}
+
+	return removed, nil
+}
+
+// isCertificateRetained will return true if the certificate with the ID is kept without a route.
+func isCertificateRetained(tx *bbolt.Tx, id []byte) bool {
+	bucket := tx.Bucket(BucketCertificates)
+	if bucket == nil {
+		return false
+	}
+
+	content := bucket.Get(id)
+	if content == nil {
+		return false
+	}
+
+	cert := new(types.Certificate)
+	return json.Unmarshal(content, cert) == nil && cert.IsRetained()
 }
 
 // GetDomainChallenge will return the latest known ACME challenge.
@@ -218,11 +372,35 @@ func (c *Client) GetDomainChallenge(domain string) *types.AcmeChallenge {
 	return challenge
 }
 
+// GetAllAcmeChallenges will return all pending ACME challenges with the key being the domain.
+func (c *Client) GetAllAcmeChallenges() map[string]types.AcmeChallenge {
+	output := make(map[string]types.AcmeChallenge)
+	_ = c.bolt.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(BucketChallenges)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(domain, content []byte) error {
+			var challenge types.AcmeChallenge
+			if err := json.Unmarshal(content, &challenge); err != nil {
+				return nil // ignore errors
+			}
+
+			output[string(domain)] = challenge
+			return nil
+		})
+	})
+
+	return output
+}
+
 // SetAcmeChallenge will persist the ACME challenge for validating a certificate request.
 func (c *Client) SetAcmeChallenge(domain string, token string, auth string) error {
 	challenge := &types.AcmeChallenge{
-		Token: token,
-		Auth:  auth,
+		Token:     token,
+		Auth:      auth,
+		CreatedAt: time.Now(),
 	}
 
 	return c.bolt.Update(func(tx *bbolt.Tx) error {
@@ -296,11 +474,20 @@ func (c *Client) GetCertificate(domain string) (*types.Certificate, error) {
 		return json.Unmarshal(content, certificate)
 	})
 
-	return certificate, err
+	if err != nil {
+		return certificate, err
+	}
+
+	return certificate, c.openCertificate(certificate)
 }
 
-// IsCertificateInUse will return true if the certificate is still being referenced by a mapping.
+// IsCertificateInUse will return true if the certificate is still being referenced by a mapping of a routed domain.
+// Retained certificates (see [types.Certificate.IsRetained]) are always in use.
 func (c *Client) IsCertificateInUse(cert types.Certificate) bool {
+	if cert.IsRetained() {
+		return true
+	}
+
 	err := c.bolt.View(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(BucketCertificateMappings)
 		if bucket == nil {
@@ -310,7 +497,7 @@ func (c *Client) IsCertificateInUse(cert types.Certificate) bool {
 
 		id := []byte(cert.ID)
 		return bucket.ForEach(func(k, v []byte) error {
-			if bytes.Equal(v, id) {
+			if bytes.Equal(v, id) && isDomainRouted(tx, string(k)) {
 				return ErrCertificateInUse
 			}
 
@@ -323,7 +510,14 @@ func (c *Client) IsCertificateInUse(cert types.Certificate) bool {
 
 // RemoveCertificateById will remove the certificate form the system with the matching ID.
 func (c *Client) RemoveCertificateById(id string) error {
+	defer c.notifyCertificatesChanged()
 	return c.bolt.Update(func(tx *bbolt.Tx) error {
+		if bucket := tx.Bucket(BucketRenewals); bucket != nil {
+			if err := bucket.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+
 		bucket := tx.Bucket(BucketCertificates)
 		if bucket == nil {
 			return nil
@@ -348,6 +542,11 @@ func (c *Client) GetAllCertificates() []types.Certificate {
 				return nil // ignore errors
 			}
 
+			if err := c.openCertificate(cert); err != nil {
+				c.logger.Warningf("Failed to decrypt private key of certificate with id=%s: %v", cert.ID, err)
+				return nil
+			}
+
 			output = append(output, *cert)
 			return nil
 		})
@@ -358,6 +557,7 @@ func (c *Client) GetAllCertificates() []types.Certificate {
 
 // SetCertificate persists the certificate configuration for the domain.
 func (c *Client) SetCertificate(cert *types.Certificate) error {
+	defer c.notifyCertificatesChanged()
 	return c.bolt.Update(func(tx *bbolt.Tx) error {
 		{
 			// upload certificate
@@ -366,7 +566,15 @@ func (c *Client) SetCertificate(cert *types.Certificate) error {
 				return err
 			}
 
-			content, err := json.Marshal(cert)
+			key, err := c.seal([]byte(cert.Key))
+			if err != nil {
+				return err
+			}
+
+			// only the stored copy holds the encrypted private key
+			stored := *cert
+			stored.Key = string(key)
+			content, err := json.Marshal(stored)
 			if err != nil {
 				return err
 			}
@@ -394,6 +602,44 @@ func (c *Client) SetCertificate(cert *types.Certificate) error {
 	})
 }
 
+// RemoveCertificate will remove the certificate and all domain mappings referring to it.
+func (c *Client) RemoveCertificate(cert *types.Certificate) error {
+	defer c.notifyCertificatesChanged()
+	return c.bolt.Update(func(tx *bbolt.Tx) error {
+		if bucket := tx.Bucket(BucketCertificateMappings); bucket != nil {
+			id := []byte(cert.ID)
+			var domains [][]byte
+			err := bucket.ForEach(func(k, v []byte) error {
+				if bytes.Equal(v, id) {
+					domains = append(domains, bytes.Clone(k))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, domain := range domains {
+				if err = bucket.Delete(domain); err != nil {
+					return err
+				}
+			}
+		}
+
+		if bucket := tx.Bucket(BucketRenewals); bucket != nil {
+			if err := bucket.Delete([]byte(cert.ID)); err != nil {
+				return err
+			}
+		}
+
+		if bucket := tx.Bucket(BucketCertificates); bucket != nil {
+			return bucket.Delete([]byte(cert.ID))
+		}
+
+		return nil
+	})
+}
+
 // getConfigContent returns the byte content from 'config' bucket.
 func (c *Client) getConfigContent(key []byte) ([]byte, error) {
 	var content []byte
@@ -414,6 +660,44 @@ func (c *Client) getConfigContent(key []byte) ([]byte, error) {
 	return content, err
 }
 
+// getSecretContent returns the decrypted byte content from 'config' bucket.
+func (c *Client) getSecretContent(key []byte) ([]byte, error) {
+	content, err := c.getConfigContent(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.open(nil, content)
+}
+
+// setSecretContent updates a key inside the 'config' bucket with the content encrypted if encryption is enabled.
+func (c *Client) setSecretContent(key []byte, content []byte) error {
+	return c.bolt.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(BucketConfig)
+		if err != nil {
+			return err
+		}
+
+		sealed, err := c.seal(content)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(key, sealed)
+	})
+}
+
+// openCertificate will decrypt the private key of the certificate if it is encrypted.
+func (c *Client) openCertificate(cert *types.Certificate) error {
+	key, err := c.open(nil, []byte(cert.Key))
+	if err != nil {
+		return err
+	}
+
+	cert.Key = string(key)
+	return nil
+}
+
 // setConfigContent updates a key inside the 'config' bucket.
 func (c *Client) setConfigContent(key []byte, content []byte) error {
 	return c.bolt.Update(func(tx *bbolt.Tx) error {
@@ -445,3 +729,170 @@ func (c *Client) Close() error {
 	}
 	return nil
 }
+
+// GetCertificateOrder will return the certificate order with the specified order key.
+func (c *Client) GetCertificateOrder(key string) (*types.CertificateOrder, error) {
+	order := new(types.CertificateOrder)
+	err := c.bolt.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(BucketOrders)
+		if bucket == nil {
+			return ErrItemNotFound
+		}
+
+		content := bucket.Get([]byte(key))
+		if content == nil {
+			return ErrItemNotFound
+		}
+
+		return json.Unmarshal(content, order)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// GetAllCertificateOrders will return all certificate orders known to the system.
+func (c *Client) GetAllCertificateOrders() []types.CertificateOrder {
+	output := make([]types.CertificateOrder, 0)
+	_ = c.bolt.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(BucketOrders)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key, content []byte) error {
+			var order types.CertificateOrder
+			if err := json.Unmarshal(content, &order); err != nil {
+				return nil // ignore errors
+			}
+
+			output = append(output, order)
+			return nil
+		})
+	})
+
+	return output
+}
+
+// SaveCertificateOrder will persist the certificate order.
+func (c *Client) SaveCertificateOrder(order *types.CertificateOrder) error {
+	return c.bolt.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(BucketOrders)
+		if err != nil {
+			return err
+		}
+
+		content, err := json.Marshal(order)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(order.Key()), content)
+	})
+}
+
+// RemoveCertificateOrder will remove the certificate order with the specified order key.
+func (c *Client) RemoveCertificateOrder(key string) error {
+	return c.bolt.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(BucketOrders)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.Delete([]byte(key))
+	})
+}
+
+// GetRenewalInfo will return the renewal information stored for the certificate with the specified ID.
+func (c *Client) GetRenewalInfo(id string) (*types.RenewalInfo, error) {
+	info := new(types.RenewalInfo)
+	err := c.bolt.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(BucketRenewals)
+		if bucket == nil {
+			return ErrItemNotFound
+		}
+
+		content := bucket.Get([]byte(id))
+		if content == nil {
+			return ErrItemNotFound
+		}
+
+		return json.Unmarshal(content, info)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// SetRenewalInfo will persist the renewal information of a certificate.
+func (c *Client) SetRenewalInfo(info *types.RenewalInfo) error {
+	return c.bolt.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(BucketRenewals)
+		if err != nil {
+			return err
+		}
+
+		content, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(info.CertificateID), content)
+	})
+}
+
+// GetNotificationSent will return the last time the notification with the specified key was sent.
+// It will return the zero time if the notification was never sent.
+func (c *Client) GetNotificationSent(key string) time.Time {
+	var sent time.Time
+	_ = c.bolt.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(BucketNotifications)
+		if bucket == nil {
+			return nil
+		}
+
+		content := bucket.Get([]byte(key))
+		if content == nil {
+			return nil
+		}
+
+		return sent.UnmarshalText(content)
+	})
+
+	return sent
+}
+
+// SetNotificationSent will persist the time the notification with the specified key was sent.
+func (c *Client) SetNotificationSent(key string, sent time.Time) error {
+	return c.bolt.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(BucketNotifications)
+		if err != nil {
+			return err
+		}
+
+		content, err := sent.MarshalText()
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(key), content)
+	})
+}
+
+// RemoveNotificationSent will forget that the notification with the specified key was sent.
+func (c *Client) RemoveNotificationSent(key string) error {
+	return c.bolt.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(BucketNotifications)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.Delete([]byte(key))
+	})
+}
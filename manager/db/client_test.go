@@ -0,0 +1,181 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/jorenkoyen/conter/manager/types"
+	"go.etcd.io/bbolt"
+)
+
+func createTestClient(t *testing.T) *Client {
+	t.Helper()
+	client := NewClient(t.TempDir())
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// hasMapping will return true if a certificate mapping exists for the domain.
+func hasMapping(c *Client, domain string) bool {
+	found := false
+	_ = c.bolt.View(func(tx *bbolt.Tx) error {
+		if bucket := tx.Bucket(BucketCertificateMappings); bucket != nil {
+			found = bucket.Get([]byte(domain)) != nil
+		}
+		return nil
+	})
+	return found
+}
+
+func TestClient_RemoveIngressRoute(t *testing.T) {
+	client := createTestClient(t)
+
+	api := &types.Ingress{Domains: []string{"www.example.com"}, Path: "/api", TargetProject: "default", TargetService: "api"}
+	web := &types.Ingress{Domains: []string{"www.example.com", "example.com"}, TargetProject: "default", TargetService: "web"}
+	for _, route := range []*types.Ingress{api, web} {
+		if err := client.SaveIngressRoute(route); err != nil {
+			t.Fatalf("Failed to save route: %v", err)
+		}
+	}
+
+	cert := &types.Certificate{ID: "cert", Domains: []string{"www.example.com", "example.com"}}
+	if err := client.SetCertificate(cert); err != nil {
+		t.Fatalf("Failed to save certificate: %v", err)
+	}
+
+	{
+		// mapping is kept while another route uses the domain
+		if err := client.RemoveIngressRoute(types.RouteKey("www.example.com", "/api")); err != nil {
+			t.Fatalf("Failed to remove route: %v", err)
+		}
+		if !hasMapping(client, "www.example.com") {
+			t.Errorf("Expected mapping for domain=www.example.com to be kept")
+		}
+	}
+
+	{
+		// mapping is removed with the last route of the domain
+		if err := client.RemoveIngressRoute(types.RouteKey("example.com", "/")); err != nil {
+			t.Fatalf("Failed to remove route: %v", err)
+		}
+		if hasMapping(client, "example.com") {
+			t.Errorf("Expected mapping for domain=example.com to be removed")
+		}
+		if !client.IsCertificateInUse(*cert) {
+			t.Errorf("Expected certificate to be in use by domain=www.example.com")
+		}
+	}
+
+	{
+		// certificate is no longer in use without any route
+		if err := client.RemoveIngressRoute(types.RouteKey("www.example.com", "/")); err != nil {
+			t.Fatalf("Failed to remove route: %v", err)
+		}
+		if hasMapping(client, "www.example.com") {
+			t.Errorf("Expected mapping for domain=www.example.com to be removed")
+		}
+		if client.IsCertificateInUse(*cert) {
+			t.Errorf("Expected certificate to no longer be in use")
+		}
+	}
+}
+
+func TestClient_RemoveIngressRoute_wildcard(t *testing.T) {
+	client := createTestClient(t)
+
+	// the wildcard certificate is serving the routes of the subdomains
+	for _, domain := range []string{"a.example.com", "b.example.com"} {
+		route := &types.Ingress{Domains: []string{domain}, TargetProject: "default", TargetService: domain}
+		if err := client.SaveIngressRoute(route); err != nil {
+			t.Fatalf("Failed to save route: %v", err)
+		}
+	}
+
+	cert := &types.Certificate{ID: "wildcard", Domains: []string{"*.example.com"}}
+	if err := client.SetCertificate(cert); err != nil {
+		t.Fatalf("Failed to save certificate: %v", err)
+	}
+
+	if err := client.RemoveIngressRoute(types.RouteKey("a.example.com", "/")); err != nil {
+		t.Fatalf("Failed to remove route: %v", err)
+	}
+	if !client.IsCertificateInUse(*cert) {
+		t.Errorf("Expected wildcard certificate to be in use by domain=b.example.com")
+	}
+
+	if err := client.RemoveIngressRoute(types.RouteKey("b.example.com", "/")); err != nil {
+		t.Fatalf("Failed to remove route: %v", err)
+	}
+	if hasMapping(client, "*.example.com") {
+		t.Errorf("Expected mapping for domain=*.example.com to be removed")
+	}
+}
+
+func TestClient_IsCertificateInUse_retained(t *testing.T) {
+	client := createTestClient(t)
+
+	cert := &types.Certificate{ID: "on-demand", Domains: []string{"customer.com"}, OnDemand: true}
+	if err := client.SetCertificate(cert); err != nil {
+		t.Fatalf("Failed to save certificate: %v", err)
+	}
+
+	// the certificate is in use without a route
+	if !client.IsCertificateInUse(*cert) {
+		t.Errorf("Expected on-demand certificate to be in use without a route")
+	}
+
+	// the mapping is not removed as a stale mapping
+	if err := client.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if !hasMapping(client, "customer.com") {
+		t.Errorf("Expected mapping of on-demand certificate to be kept")
+	}
+}
+
+func TestClient_IsDomainRouted(t *testing.T) {
+	client := createTestClient(t)
+
+	for _, domain := range []string{"www.example.com", "*.apps.example.com"} {
+		route := &types.Ingress{Domains: []string{domain}, TargetProject: "default", TargetService: domain}
+		if err := client.SaveIngressRoute(route); err != nil {
+			t.Fatalf("Failed to save route: %v", err)
+		}
+	}
+
+	// a wildcard covering a route and a domain covered by a wildcard route are routed
+	for _, domain := range []string{"www.example.com", "*.example.com", "a.apps.example.com"} {
+		if !client.IsDomainRouted(domain) {
+			t.Errorf("Expected domain=%s to be routed", domain)
+		}
+	}
+
+	if client.IsDomainRouted("other.example.com") {
+		t.Errorf("Expected domain=other.example.com to not be routed")
+	}
+}
+
+func TestRemoveStaleCertificateMappings(t *testing.T) {
+	client := createTestClient(t)
+
+	route := &types.Ingress{Domains: []string{"www.example.com"}, TargetProject: "default", TargetService: "www"}
+	if err := client.SaveIngressRoute(route); err != nil {
+		t.Fatalf("Failed to save route: %v", err)
+	}
+
+	// mapping left behind by a route removed before mappings were cleaned up
+	cert := &types.Certificate{ID: "cert", Domains: []string{"www.example.com", "old.example.com"}}
+	if err := client.SetCertificate(cert); err != nil {
+		t.Fatalf("Failed to save certificate: %v", err)
+	}
+
+	if err := client.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	if hasMapping(client, "old.example.com") {
+		t.Errorf("Expected stale mapping for domain=old.example.com to be removed")
+	}
+	if !hasMapping(client, "www.example.com") {
+		t.Errorf("Expected mapping for domain=www.example.com to be kept")
+	}
+}
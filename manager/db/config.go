@@ -6,6 +6,8 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"github.com/go-acme/lego/v4/registration"
 	"github.com/jorenkoyen/go-logger/log"
 )
@@ -15,10 +17,17 @@ var (
 	KeyAcmePrivateKey   = []byte("acme.private_key")
 	KeyAcmeRegistration = []byte("acme.registration")
 	KeyAcmeDirectory    = []byte("acme.directory")
+
+	KeyCACertificate = []byte("ca.certificate")
+	KeyCAPrivateKey  = []byte("ca.private_key")
 )
 
+// DefaultIssuer is the name of the ACME issuer that uses the configuration keys without an issuer prefix.
+const DefaultIssuer = "default"
+
 type Config struct {
 	client *Client
+	prefix string
 }
 
 // NewConfigDatabase creates a new database that only interacts with the configuration bucket.
@@ -26,9 +35,24 @@ func NewConfigDatabase(c *Client) *Config {
 	return &Config{client: c}
 }
 
+// ForIssuer will return the configuration database for the ACME registration of the named issuer.
+// The default issuer shares its keys with the registration created before multiple issuers were supported.
+func (c *Config) ForIssuer(name string) *Config {
+	if name == DefaultIssuer {
+		return &Config{client: c.client}
+	}
+
+	return &Config{client: c.client, prefix: "issuers." + name + "."}
+}
+
+// key will return the configuration key including the issuer prefix.
+func (c *Config) key(key []byte) []byte {
+	return append([]byte(c.prefix), key...)
+}
+
 // GetAcmeEmail will return the email address of the ACME user.
 func (c *Config) GetAcmeEmail() string {
-	content, err := c.client.getConfigContent(KeyAcmeEmail)
+	content, err := c.client.getConfigContent(c.key(KeyAcmeEmail))
 	if err != nil {
 		return ""
 	}
@@ -38,40 +62,34 @@ func (c *Config) GetAcmeEmail() string {
 
 // SetAcmeEmail will configure the ACME user email.
 func (c *Config) SetAcmeEmail(email string) {
-	err := c.client.setConfigContent(KeyAcmeEmail, []byte(email))
+	err := c.client.setConfigContent(c.key(KeyAcmeEmail), []byte(email))
 	if err != nil {
 		log.Panicf("Failed to set content for ACME email: %v", err)
 	}
 }
 
 // GetAcmePrivateKey will return the private key used to register the user via ACME.
-func (c *Config) GetAcmePrivateKey() crypto.PrivateKey {
-	content, err := c.client.getConfigContent(KeyAcmePrivateKey)
-	if err != nil {
-		return nil
+// It will return nil without error if no private key has been stored yet.
+func (c *Config) GetAcmePrivateKey() (crypto.PrivateKey, error) {
+	content, err := c.client.getSecretContent(c.key(KeyAcmePrivateKey))
+	if errors.Is(err, ErrItemNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read ACME private key: %w", err)
 	}
 
 	// Decode the PEM block
 	block, _ := pem.Decode(content)
 	if block == nil || block.Type != "PRIVATE KEY" && block.Type != "RSA PRIVATE KEY" && block.Type != "EC PRIVATE KEY" {
-		log.Panicf("failed to decode PEM block containing private key")
-		return nil
+		return nil, errors.New("failed to decode PEM block containing private key")
 	}
 
-	// Parse the private key based on its type
-	var parsedKey crypto.PrivateKey
-	parsedKey, err = x509.ParseECPrivateKey(block.Bytes)
+	key, err := x509.ParseECPrivateKey(block.Bytes)
 	if err != nil {
-		log.Panicf("failed to parse private key: %v", err)
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
 	}
 
-	switch key := parsedKey.(type) {
-	case *ecdsa.PrivateKey:
-		return key
-	default:
-		log.Panicf("unsupported private key type")
-		return nil
-	}
+	return key, nil
 }
 
 // SetAcmePrivateKey will persist the ACME private key into the configuration bucket.
@@ -97,7 +115,7 @@ func (c *Config) SetAcmePrivateKey(privateKey crypto.PrivateKey) {
 	}
 
 	content := pem.EncodeToMemory(pemBlock)
-	err = c.client.setConfigContent(KeyAcmePrivateKey, content)
+	err = c.client.setSecretContent(c.key(KeyAcmePrivateKey), content)
 	if err != nil {
 		log.Panicf("failed to set content for ACME private key: %v", err)
 	}
@@ -105,7 +123,7 @@ func (c *Config) SetAcmePrivateKey(privateKey crypto.PrivateKey) {
 
 // GetAcmeRegistration will return the registration resource we got from the ACME authority.
 func (c *Config) GetAcmeRegistration() *registration.Resource {
-	content, err := c.client.getConfigContent(KeyAcmeRegistration)
+	content, err := c.client.getConfigContent(c.key(KeyAcmeRegistration))
 	if err != nil {
 		return nil
 	}
@@ -125,7 +143,7 @@ func (c *Config) SetAcmeRegistration(registration *registration.Resource) {
 	if err != nil {
 		log.Panicf("failed to marshal registration: %v", err)
 	}
-	err = c.client.setConfigContent(KeyAcmeRegistration, content)
+	err = c.client.setConfigContent(c.key(KeyAcmeRegistration), content)
 	if err != nil {
 		log.Panicf("failed to set content for ACME registration: %v", err)
 	}
@@ -133,7 +151,7 @@ func (c *Config) SetAcmeRegistration(registration *registration.Resource) {
 
 // SetAcmeDirectory will persist the directory URL used during the user registration process.
 func (c *Config) SetAcmeDirectory(directory string) {
-	err := c.client.setConfigContent(KeyAcmeDirectory, []byte(directory))
+	err := c.client.setConfigContent(c.key(KeyAcmeDirectory), []byte(directory))
 	if err != nil {
 		log.Panicf("failed to set content for ACME directory: %v", err)
 	}
@@ -141,7 +159,7 @@ func (c *Config) SetAcmeDirectory(directory string) {
 
 // GetAcmeDirectory will return the directory used when registering the user.
 func (c *Config) GetAcmeDirectory() string {
-	content, err := c.client.getConfigContent(KeyAcmeDirectory)
+	content, err := c.client.getConfigContent(c.key(KeyAcmeDirectory))
 	if err != nil {
 		return ""
 	}
@@ -149,16 +167,44 @@ func (c *Config) GetAcmeDirectory() string {
 }
 
 func (c *Config) ClearAcme() {
-	if err := c.client.removeConfigContent(KeyAcmeEmail); err != nil {
+	if err := c.client.removeConfigContent(c.key(KeyAcmeEmail)); err != nil {
 		log.Panicf("Failed to remove ACME email: %v", err)
 	}
-	if err := c.client.removeConfigContent(KeyAcmeRegistration); err != nil {
+	if err := c.client.removeConfigContent(c.key(KeyAcmeRegistration)); err != nil {
 		log.Panicf("Failed to remove ACME registration: %v", err)
 	}
-	if err := c.client.removeConfigContent(KeyAcmePrivateKey); err != nil {
+	if err := c.client.removeConfigContent(c.key(KeyAcmePrivateKey)); err != nil {
 		log.Panicf("Failed to remove ACME private key: %v", err)
 	}
-	if err := c.client.removeConfigContent(KeyAcmeDirectory); err != nil {
+	if err := c.client.removeConfigContent(c.key(KeyAcmeDirectory)); err != nil {
 		log.Panicf("Failed to remove ACME directory: %v", err)
 	}
 }
+
+// GetCertificateAuthority will return the PEM encoded certificate and private key of the internal certificate authority.
+// It will return nil if the certificate authority has not been created.
+func (c *Config) GetCertificateAuthority() ([]byte, []byte) {
+	certificate, err := c.client.getConfigContent(c.key(KeyCACertificate))
+	if err != nil {
+		return nil, nil
+	}
+
+	key, err := c.client.getSecretContent(c.key(KeyCAPrivateKey))
+	if errors.Is(err, ErrItemNotFound) {
+		return nil, nil
+	} else if err != nil {
+		log.Panicf("failed to read CA private key: %v", err)
+	}
+
+	return certificate, key
+}
+
+// SetCertificateAuthority will persist the PEM encoded certificate and private key of the internal certificate authority.
+func (c *Config) SetCertificateAuthority(certificate []byte, key []byte) {
+	if err := c.client.setConfigContent(c.key(KeyCACertificate), certificate); err != nil {
+		log.Panicf("failed to set content for CA certificate: %v", err)
+	}
+	if err := c.client.setSecretContent(c.key(KeyCAPrivateKey), key); err != nil {
+		log.Panicf("failed to set content for CA private key: %v", err)
+	}
+}
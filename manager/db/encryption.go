@@ -0,0 +1,431 @@
+package db
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jorenkoyen/conter/manager/types"
+	"go.etcd.io/bbolt"
+)
+
+// EncryptionKeySize is the size in bytes of the master key and the data keys.
+const EncryptionKeySize = 32
+
+// encryptedPrefix marks a value encrypted with a data key, it is followed by the key ID and the ciphertext.
+const encryptedPrefix = "enc:v1:"
+
+var (
+	// KeyEncryptionActive holds the ID of the data key used for encrypting new values.
+	KeyEncryptionActive = []byte("encryption.active")
+	// KeyEncryptionKeys is the prefix of the data keys, each data key is stored encrypted with the master key.
+	KeyEncryptionKeys = []byte("encryption.keys.")
+
+	ErrEncryptionDisabled    = errors.New("value is encrypted but no encryption key is configured")
+	ErrEncryptionKeyRequired = errors.New("database contains encrypted private keys but no encryption key is configured, set 'encryption.key_file' or 'encryption.key_env'")
+)
+
+// ParseEncryptionKey will decode the base64 encoded master key.
+func ParseEncryptionKey(content []byte) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be base64 encoded: %w", err)
+	}
+
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", EncryptionKeySize, len(key))
+	}
+
+	return key, nil
+}
+
+// keyring contains the data keys used for envelope encryption.
+type keyring struct {
+	master cipher.AEAD
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// seal will encrypt the plaintext with the active data key.
+func (k *keyring) seal(plaintext []byte) ([]byte, error) {
+	ciphertext, err := encrypt(k.keys[k.active], plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(encryptedPrefix + k.active + ":" + base64.StdEncoding.EncodeToString(ciphertext)), nil
+}
+
+// open will decrypt the value with the data key it was encrypted with.
+func (k *keyring) open(value []byte) ([]byte, error) {
+	id, content, ok := strings.Cut(strings.TrimPrefix(string(value), encryptedPrefix), ":")
+	if !ok {
+		return nil, errors.New("malformed encrypted value")
+	}
+
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown data key=%s", id)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return decrypt(key, ciphertext)
+}
+
+// generate will create a new data key and make it the active key.
+// The data key is returned encrypted with the master key.
+func (k *keyring) generate() (string, []byte, error) {
+	raw := make([]byte, EncryptionKeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+
+	key, err := newAEAD(raw)
+	if err != nil {
+		return "", nil, err
+	}
+
+	wrapped, err := encrypt(k.master, raw)
+	if err != nil {
+		return "", nil, err
+	}
+
+	k.active = hex.EncodeToString(id)
+	k.keys[k.active] = key
+	return k.active, wrapped, nil
+}
+
+// clone will return a copy of the keyring that can be modified without affecting the original.
+func (k *keyring) clone() *keyring {
+	keys := make(map[string]cipher.AEAD, len(k.keys))
+	for id, key := range k.keys {
+		keys[id] = key
+	}
+
+	return &keyring{master: k.master, active: k.active, keys: keys}
+}
+
+// EnableEncryption will encrypt all private keys stored from now on with a data key protected by the master key.
+// The data key is created on first use, existing values are encrypted by running the migrations.
+func (c *Client) EnableEncryption(master []byte) error {
+	aead, err := newAEAD(master)
+	if err != nil {
+		return err
+	}
+
+	ring := &keyring{master: aead, keys: make(map[string]cipher.AEAD)}
+	err = c.bolt.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(BucketConfig)
+		if err != nil {
+			return err
+		}
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.Seek(KeyEncryptionKeys); k != nil && bytes.HasPrefix(k, KeyEncryptionKeys); k, v = cursor.Next() {
+			raw, err := decrypt(aead, v)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt data key, is the master key correct? %w", err)
+			}
+
+			key, err := newAEAD(raw)
+			if err != nil {
+				return err
+			}
+
+			ring.keys[string(bytes.TrimPrefix(k, KeyEncryptionKeys))] = key
+		}
+
+		if active := bucket.Get(KeyEncryptionActive); active != nil {
+			ring.active = string(active)
+			if _, ok := ring.keys[ring.active]; !ok {
+				return fmt.Errorf("active data key=%s is missing", ring.active)
+			}
+			return nil
+		}
+
+		id, wrapped, err := ring.generate()
+		if err != nil {
+			return err
+		}
+
+		c.logger.Infof("Generated new data encryption key with id=%s", id)
+		if err = bucket.Put([]byte(string(KeyEncryptionKeys)+id), wrapped); err != nil {
+			return err
+		}
+
+		return bucket.Put(KeyEncryptionActive, []byte(id))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	c.keyringMutex.Lock()
+	c.keyring = ring
+	c.keyringMutex.Unlock()
+	return nil
+}
+
+// RotateEncryptionKey will generate a new data key and re-encrypt all private keys with it.
+// The previous data keys are removed from the database. It returns the ID of the new data key.
+func (c *Client) RotateEncryptionKey() (string, error) {
+	var id string
+	var previous *keyring
+	err := c.bolt.Update(func(tx *bbolt.Tx) error {
+		c.keyringMutex.RLock()
+		previous = c.keyring
+		c.keyringMutex.RUnlock()
+
+		if previous == nil {
+			return errors.New("encryption is not enabled")
+		}
+
+		bucket, err := tx.CreateBucketIfNotExists(BucketConfig)
+		if err != nil {
+			return err
+		}
+
+		// previous keys are kept in memory for values being read while rotating
+		ring := previous.clone()
+		var wrapped []byte
+		id, wrapped, err = ring.generate()
+		if err != nil {
+			return err
+		}
+
+		var keys [][]byte
+		cursor := bucket.Cursor()
+		for k, _ := cursor.Seek(KeyEncryptionKeys); k != nil && bytes.HasPrefix(k, KeyEncryptionKeys); k, _ = cursor.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+
+		for _, k := range keys {
+			if err = bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		if err = bucket.Put([]byte(string(KeyEncryptionKeys)+id), wrapped); err != nil {
+			return err
+		}
+
+		if err = bucket.Put(KeyEncryptionActive, []byte(id)); err != nil {
+			return err
+		}
+
+		if err = c.reencrypt(tx, ring); err != nil {
+			return err
+		}
+
+		// writers are serialized, every value written after this transaction uses the new key
+		c.keyringMutex.Lock()
+		c.keyring = ring
+		c.keyringMutex.Unlock()
+		return nil
+	})
+
+	if err != nil {
+		if previous != nil {
+			c.keyringMutex.Lock()
+			c.keyring = previous
+			c.keyringMutex.Unlock()
+		}
+		return "", err
+	}
+
+	c.logger.Infof("Rotated data encryption key, all private keys are encrypted with key id=%s", id)
+	return id, nil
+}
+
+// reencrypt will encrypt all private keys with the active data key of the keyring.
+// Plaintext values are encrypted, values encrypted with another data key are decrypted first.
+func (c *Client) reencrypt(tx *bbolt.Tx, ring *keyring) error {
+	if bucket := tx.Bucket(BucketConfig); bucket != nil {
+		var keys [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			if isSecretConfigKey(k) {
+				keys = append(keys, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			content, err := c.open(ring, bucket.Get(k))
+			if err != nil {
+				return fmt.Errorf("config=%s: %w", k, err)
+			}
+
+			sealed, err := ring.seal(content)
+			if err != nil {
+				return err
+			}
+
+			if err = bucket.Put(k, sealed); err != nil {
+				return err
+			}
+		}
+	}
+
+	if bucket := tx.Bucket(BucketCertificates); bucket != nil {
+		updated := make(map[string][]byte)
+		err := bucket.ForEach(func(id, content []byte) error {
+			var cert types.Certificate
+			if err := json.Unmarshal(content, &cert); err != nil {
+				return nil // ignore errors
+			}
+
+			key, err := c.open(ring, []byte(cert.Key))
+			if err != nil {
+				return fmt.Errorf("certificate=%s: %w", id, err)
+			}
+
+			sealed, err := ring.seal(key)
+			if err != nil {
+				return err
+			}
+
+			cert.Key = string(sealed)
+			updated[string(id)], err = json.Marshal(cert)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		for id, content := range updated {
+			if err = bucket.Put([]byte(id), content); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkEncryption will return an error if private keys are stored encrypted while no encryption key is configured.
+// The daemon would otherwise only fail once it needs one of the private keys.
+func (c *Client) checkEncryption(tx *bbolt.Tx) error {
+	c.keyringMutex.RLock()
+	ring := c.keyring
+	c.keyringMutex.RUnlock()
+
+	if ring != nil {
+		return nil
+	}
+
+	encrypted := func(content []byte) bool { return bytes.HasPrefix(content, []byte(encryptedPrefix)) }
+
+	if bucket := tx.Bucket(BucketConfig); bucket != nil {
+		err := bucket.ForEach(func(k, v []byte) error {
+			if isSecretConfigKey(k) && encrypted(v) {
+				return ErrEncryptionKeyRequired
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if bucket := tx.Bucket(BucketCertificates); bucket != nil {
+		return bucket.ForEach(func(_, content []byte) error {
+			var cert types.Certificate
+			if err := json.Unmarshal(content, &cert); err != nil {
+				return nil // ignore errors
+			}
+
+			if encrypted([]byte(cert.Key)) {
+				return ErrEncryptionKeyRequired
+			}
+			return nil
+		})
+	}
+
+	return nil
+}
+
+// seal will encrypt the secret if encryption is enabled.
+func (c *Client) seal(content []byte) ([]byte, error) {
+	c.keyringMutex.RLock()
+	ring := c.keyring
+	c.keyringMutex.RUnlock()
+
+	if ring == nil {
+		return content, nil
+	}
+
+	return ring.seal(content)
+}
+
+// open will decrypt the secret if it is encrypted, plaintext values are returned as is.
+// When no keyring is given the keyring of the client is used.
+func (c *Client) open(ring *keyring, content []byte) ([]byte, error) {
+	if !bytes.HasPrefix(content, []byte(encryptedPrefix)) {
+		return content, nil
+	}
+
+	if ring == nil {
+		c.keyringMutex.RLock()
+		ring = c.keyring
+		c.keyringMutex.RUnlock()
+	}
+
+	if ring == nil {
+		return nil, ErrEncryptionDisabled
+	}
+
+	return ring.open(content)
+}
+
+// isSecretConfigKey will return true if the configuration key holds a private key.
+func isSecretConfigKey(key []byte) bool {
+	return bytes.HasSuffix(key, KeyAcmePrivateKey) || bytes.Equal(key, KeyCAPrivateKey)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encrypt will encrypt the plaintext and prepend the random nonce.
+func encrypt(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decrypt will decrypt the ciphertext created by encrypt.
+func decrypt(aead cipher.AEAD, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, content := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, content, nil)
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jorenkoyen/conter/manager/types"
+	"go.etcd.io/bbolt"
+)
+
+func createMasterKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, EncryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Failed to generate master key: %v", err)
+	}
+	return key
+}
+
+// rawCertificateKey will return the private key of the certificate as it is stored in the database.
+func rawCertificateKey(t *testing.T, c *Client, id string) string {
+	t.Helper()
+	var raw string
+	_ = c.bolt.View(func(tx *bbolt.Tx) error {
+		content := tx.Bucket(BucketCertificates).Get([]byte(id))
+		raw = string(content)
+		return nil
+	})
+	return raw
+}
+
+func TestParseEncryptionKey(t *testing.T) {
+	key := createMasterKey(t)
+
+	parsed, err := ParseEncryptionKey([]byte(base64.StdEncoding.EncodeToString(key) + "\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse encryption key: %v", err)
+	}
+	if !bytes.Equal(key, parsed) {
+		t.Errorf("Expected parsed key to match")
+	}
+
+	if _, err = ParseEncryptionKey([]byte("not-base64")); err == nil {
+		t.Errorf("Expected an error for a key that is not base64 encoded")
+	}
+
+	if _, err = ParseEncryptionKey([]byte(base64.StdEncoding.EncodeToString(key[:16]))); err == nil {
+		t.Errorf("Expected an error for a key with an invalid size")
+	}
+}
+
+func TestClient_Encryption(t *testing.T) {
+	directory := t.TempDir()
+	master := createMasterKey(t)
+	client := NewClient(directory)
+
+	// entries stored before encryption was enabled
+	route := &types.Ingress{Domains: []string{"www.example.com"}, TargetProject: "default", TargetService: "www"}
+	if err := client.SaveIngressRoute(route); err != nil {
+		t.Fatalf("Failed to save route: %v", err)
+	}
+
+	cert := &types.Certificate{ID: "plain", Key: "cGxhaW4ta2V5", Domains: []string{"www.example.com"}}
+	if err := client.SetCertificate(cert); err != nil {
+		t.Fatalf("Failed to save certificate: %v", err)
+	}
+
+	accountKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	NewConfigDatabase(client).SetAcmePrivateKey(accountKey)
+
+	{
+		// migration is skipped until encryption is enabled
+		if err := client.Migrate(); err != nil {
+			t.Fatalf("Failed to migrate: %v", err)
+		}
+		if slices.Contains(client.GetAppliedMigrations(), "encrypt_private_keys") {
+			t.Errorf("Expected encryption migration to be skipped")
+		}
+	}
+
+	{
+		// migration encrypts the existing entries
+		if err := client.EnableEncryption(master); err != nil {
+			t.Fatalf("Failed to enable encryption: %v", err)
+		}
+		if err := client.Migrate(); err != nil {
+			t.Fatalf("Failed to migrate: %v", err)
+		}
+
+		if strings.Contains(rawCertificateKey(t, client, "plain"), cert.Key) {
+			t.Errorf("Expected certificate key to be encrypted after migration")
+		}
+
+		content, _ := client.getConfigContent(KeyAcmePrivateKey)
+		if !bytes.HasPrefix(content, []byte(encryptedPrefix)) {
+			t.Errorf("Expected ACME private key to be encrypted after migration")
+		}
+
+		stored, err := client.GetCertificate("www.example.com")
+		if err != nil {
+			t.Fatalf("Failed to retrieve certificate: %v", err)
+		}
+		if stored.Key != cert.Key {
+			t.Errorf("Expected decrypted certificate key to match")
+		}
+	}
+
+	{
+		// rotation re-encrypts everything with a new data key
+		before := rawCertificateKey(t, client, "plain")
+		id, err := client.RotateEncryptionKey()
+		if err != nil {
+			t.Fatalf("Failed to rotate encryption key: %v", err)
+		}
+
+		after := rawCertificateKey(t, client, "plain")
+		if before == after || !strings.Contains(after, encryptedPrefix+id+":") {
+			t.Errorf("Expected certificate key to be encrypted with the new data key")
+		}
+
+		if key, err := NewConfigDatabase(client).GetAcmePrivateKey(); err != nil || !key.(*ecdsa.PrivateKey).Equal(accountKey) {
+			t.Errorf("Expected ACME private key to be readable after rotation")
+		}
+	}
+
+	_ = client.Close()
+
+	{
+		// encrypted entries are readable after a restart with the same master key
+		client = NewClient(directory)
+		t.Cleanup(func() { _ = client.Close() })
+
+		if _, err := client.GetCertificate("www.example.com"); err == nil {
+			t.Errorf("Expected an error when reading encrypted entries without a master key")
+		}
+
+		if _, err := NewConfigDatabase(client).GetAcmePrivateKey(); !errors.Is(err, ErrEncryptionDisabled) {
+			t.Errorf("Expected an error when reading the ACME private key without a master key, got: %v", err)
+		}
+
+		if err := client.Migrate(); !errors.Is(err, ErrEncryptionKeyRequired) {
+			t.Errorf("Expected migrations to refuse encrypted entries without a master key, got: %v", err)
+		}
+
+		if err := client.EnableEncryption(createMasterKey(t)); err == nil {
+			t.Errorf("Expected an error when enabling encryption with another master key")
+		}
+
+		if err := client.EnableEncryption(master); err != nil {
+			t.Fatalf("Failed to enable encryption: %v", err)
+		}
+
+		stored, err := client.GetCertificate("www.example.com")
+		if err != nil {
+			t.Fatalf("Failed to retrieve certificate: %v", err)
+		}
+		if stored.Key != cert.Key {
+			t.Errorf("Expected decrypted certificate key to match")
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var BucketMigrations = []byte("migrations")
+
+// errMigrationSkipped can be returned by a migration that does not apply yet, it will be attempted again on the next start.
+var errMigrationSkipped = errors.New("migration skipped")
+
+// Migration is a one-time modification of the stored data.
+type Migration struct {
+	Name string
+	Run  func(c *Client, tx *bbolt.Tx) error
+}
+
+// Migrations are all data migrations in the order they have to be applied.
+var Migrations = []Migration{
+	{Name: "encrypt_private_keys", Run: encryptPrivateKeys},
+	{Name: "remove_stale_certificate_mappings", Run: removeStaleCertificateMappings},
+}
+
+// Migrate will apply all migrations that have not been applied yet.
+// Each migration runs in its own transaction and is recorded once it succeeded.
+// It will fail if encrypted private keys are stored while encryption is not enabled.
+func (c *Client) Migrate() error {
+	if err := c.bolt.View(c.checkEncryption); err != nil {
+		return err
+	}
+
+	for _, migration := range Migrations {
+		err := c.bolt.Update(func(tx *bbolt.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(BucketMigrations)
+			if err != nil {
+				return err
+			}
+
+			if bucket.Get([]byte(migration.Name)) != nil {
+				return errMigrationSkipped // already applied
+			}
+
+			if err = migration.Run(c, tx); err != nil {
+				return err
+			}
+
+			c.logger.Infof("Applied database migration=%s", migration.Name)
+			applied, _ := time.Now().MarshalText()
+			return bucket.Put([]byte(migration.Name), applied)
+		})
+
+		if errors.Is(err, errMigrationSkipped) {
+			continue
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAppliedMigrations will return the names of all migrations applied to the database.
+func (c *Client) GetAppliedMigrations() []string {
+	output := make([]string, 0)
+	_ = c.bolt.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(BucketMigrations)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(name, _ []byte) error {
+			output = append(output, string(name))
+			return nil
+		})
+	})
+
+	return output
+}
+
+// encryptPrivateKeys will encrypt all private keys stored before encryption was enabled.
+// It is skipped until encryption is enabled.
+func encryptPrivateKeys(c *Client, tx *bbolt.Tx) error {
+	c.keyringMutex.RLock()
+	ring := c.keyring
+	c.keyringMutex.RUnlock()
+
+	if ring == nil {
+		return errMigrationSkipped
+	}
+
+	return c.reencrypt(tx, ring)
+}
+
+// removeStaleCertificateMappings will remove the certificate mappings left behind by routes removed before
+// mappings were tied to the routes. The certificates themselves are removed by the next certificate batch.
+func removeStaleCertificateMappings(c *Client, tx *bbolt.Tx) error {
+	bucket := tx.Bucket(BucketCertificateMappings)
+	if bucket == nil {
+		return nil
+	}
+
+	var domains []string
+	err := bucket.ForEach(func(domain, _ []byte) error {
+		domains = append(domains, string(domain))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = removeUnroutedMappings(tx, domains)
+	return err
+}
@@ -0,0 +1,264 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/providers/dns/exec"
+	"github.com/go-acme/lego/v4/providers/dns/gandiv5"
+	"github.com/go-acme/lego/v4/providers/dns/hetzner"
+	"github.com/go-acme/lego/v4/providers/dns/httpreq"
+	"github.com/go-acme/lego/v4/providers/dns/rfc2136"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+// dnsProviders contains the DNS providers that can be used for solving DNS-01 challenges.
+// Each provider is created from its LEGO configuration, the credentials use the environment variable names documented by LEGO.
+var dnsProviders = map[string]func(credentials dnsCredentials) (challenge.Provider, error){
+	"exec":    newExecProvider,
+	"gandiv5": newGandiProvider,
+	"hetzner": newHetznerProvider,
+	"httpreq": newHTTPRequestProvider,
+	"rfc2136": newRFC2136Provider,
+}
+
+// DNSProviders will return the names of all supported DNS providers.
+func DNSProviders() []string {
+	names := make([]string, 0, len(dnsProviders))
+	for name := range dnsProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DNSOptions contains the configuration for solving DNS-01 challenges.
+type DNSOptions struct {
+	// Provider is the name of the DNS provider (see [DNSProviders]).
+	Provider string
+	// Credentials configure the provider, they are keyed by the environment variable names documented by LEGO.
+	Credentials map[string]string
+	// Resolvers are the recursive nameservers used to check the propagation of the TXT records.
+	Resolvers []string
+	// DisablePropagationCheck skips waiting for the authoritative nameservers, useful for local testing.
+	DisablePropagationCheck bool
+}
+
+// NewDNSProvider will create the DNS provider with the specified name.
+// The credentials are only passed to the provider, the environment of the daemon is left untouched.
+func NewDNSProvider(name string, credentials map[string]string) (challenge.Provider, error) {
+	create, ok := dnsProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("DNS provider=%s is not supported (supported=%v)", name, DNSProviders())
+	}
+
+	provider, err := create(credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DNS provider=%s: %w", name, err)
+	}
+
+	return provider, nil
+}
+
+// ConfigureDNS will enable the DNS-01 challenge for every issuer using the DNS provider from the options.
+func (c *CertificateManager) ConfigureDNS(opts DNSOptions) error {
+	issuers := c.activeIssuers()
+	if len(issuers) == 0 {
+		c.logger.Warningf("Not configuring DNS provider=%s, ACME email is not configured", opts.Provider)
+		return nil
+	}
+
+	provider, err := NewDNSProvider(opts.Provider, opts.Credentials)
+	if err != nil {
+		return err
+	}
+
+	challengeOpts := []dns01.ChallengeOption{
+		dns01.CondOption(len(opts.Resolvers) > 0, dns01.AddRecursiveNameservers(dns01.ParseNameservers(opts.Resolvers))),
+		dns01.CondOption(opts.DisablePropagationCheck, dns01.DisableAuthoritativeNssPropagationRequirement()),
+	}
+
+	for _, iss := range issuers {
+		err = c.enable(iss, types.ChallengeTypeDNS, func(client *lego.Client) error {
+			return client.Challenge.SetDNS01Provider(provider, challengeOpts...)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to register DNS-01 provider: %w", err)
+		}
+	}
+
+	c.logger.Infof("Configured DNS-01 challenges using provider=%s", opts.Provider)
+	return nil
+}
+
+// IsDNSProviderSupported will return true if the DNS provider with the given name can be used.
+func IsDNSProviderSupported(name string) bool {
+	return slices.Contains(DNSProviders(), name)
+}
+
+// dnsCredentials are the credentials of a DNS provider keyed by their LEGO environment variable name.
+type dnsCredentials map[string]string
+
+// required will return the credential, it will return an error when it is not set.
+func (c dnsCredentials) required(key string) (string, error) {
+	if value := c[key]; value != "" {
+		return value, nil
+	}
+
+	return "", fmt.Errorf("credential=%s is required", key)
+}
+
+// seconds will overwrite the duration with the credential in seconds when it is set.
+func (c dnsCredentials) seconds(key string, target *time.Duration) error {
+	value := c[key]
+	if value == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("credential=%s must be a number of seconds", key)
+	}
+
+	*target = time.Duration(n) * time.Second
+	return nil
+}
+
+// integer will overwrite the number with the credential when it is set.
+func (c dnsCredentials) integer(key string, target *int) error {
+	value := c[key]
+	if value == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("credential=%s must be a number", key)
+	}
+
+	*target = n
+	return nil
+}
+
+func newExecProvider(credentials dnsCredentials) (challenge.Provider, error) {
+	program, err := credentials.required(exec.EnvPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := exec.NewDefaultConfig()
+	config.Program = program
+	config.Mode = credentials[exec.EnvMode]
+	err = errors.Join(
+		credentials.seconds(exec.EnvPropagationTimeout, &config.PropagationTimeout),
+		credentials.seconds(exec.EnvPollingInterval, &config.PollingInterval),
+		credentials.seconds(exec.EnvSequenceInterval, &config.SequenceInterval),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return exec.NewDNSProviderConfig(config)
+}
+
+func newGandiProvider(credentials dnsCredentials) (challenge.Provider, error) {
+	config := gandiv5.NewDefaultConfig()
+	config.APIKey = credentials[gandiv5.EnvAPIKey]
+	config.PersonalAccessToken = credentials[gandiv5.EnvPersonalAccessToken]
+	err := errors.Join(
+		credentials.integer(gandiv5.EnvTTL, &config.TTL),
+		credentials.seconds(gandiv5.EnvPropagationTimeout, &config.PropagationTimeout),
+		credentials.seconds(gandiv5.EnvPollingInterval, &config.PollingInterval),
+		credentials.seconds(gandiv5.EnvHTTPTimeout, &config.HTTPClient.Timeout),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return gandiv5.NewDNSProviderConfig(config)
+}
+
+func newHetznerProvider(credentials dnsCredentials) (challenge.Provider, error) {
+	key, err := credentials.required(hetzner.EnvAPIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	config := hetzner.NewDefaultConfig()
+	config.APIKey = key
+	err = errors.Join(
+		credentials.integer(hetzner.EnvTTL, &config.TTL),
+		credentials.seconds(hetzner.EnvPropagationTimeout, &config.PropagationTimeout),
+		credentials.seconds(hetzner.EnvPollingInterval, &config.PollingInterval),
+		credentials.seconds(hetzner.EnvHTTPTimeout, &config.HTTPClient.Timeout),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return hetzner.NewDNSProviderConfig(config)
+}
+
+func newHTTPRequestProvider(credentials dnsCredentials) (challenge.Provider, error) {
+	raw, err := credentials.required(httpreq.EnvEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("credential=%s is not a valid URL: %w", httpreq.EnvEndpoint, err)
+	}
+
+	config := httpreq.NewDefaultConfig()
+	config.Endpoint = endpoint
+	config.Mode = credentials[httpreq.EnvMode]
+	config.Username = credentials[httpreq.EnvUsername]
+	config.Password = credentials[httpreq.EnvPassword]
+	err = errors.Join(
+		credentials.seconds(httpreq.EnvPropagationTimeout, &config.PropagationTimeout),
+		credentials.seconds(httpreq.EnvPollingInterval, &config.PollingInterval),
+		credentials.seconds(httpreq.EnvHTTPTimeout, &config.HTTPClient.Timeout),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return httpreq.NewDNSProviderConfig(config)
+}
+
+func newRFC2136Provider(credentials dnsCredentials) (challenge.Provider, error) {
+	nameserver, err := credentials.required(rfc2136.EnvNameserver)
+	if err != nil {
+		return nil, err
+	}
+
+	config := rfc2136.NewDefaultConfig()
+	config.Nameserver = nameserver
+	config.TSIGFile = credentials[rfc2136.EnvTSIGFile]
+	config.TSIGKey = credentials[rfc2136.EnvTSIGKey]
+	config.TSIGSecret = credentials[rfc2136.EnvTSIGSecret]
+	if algorithm := credentials[rfc2136.EnvTSIGAlgorithm]; algorithm != "" {
+		config.TSIGAlgorithm = algorithm
+	}
+	err = errors.Join(
+		credentials.integer(rfc2136.EnvTTL, &config.TTL),
+		credentials.seconds(rfc2136.EnvPropagationTimeout, &config.PropagationTimeout),
+		credentials.seconds(rfc2136.EnvPollingInterval, &config.PollingInterval),
+		credentials.seconds(rfc2136.EnvSequenceInterval, &config.SequenceInterval),
+		credentials.seconds(rfc2136.EnvDNSTimeout, &config.DNSTimeout),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return rfc2136.NewDNSProviderConfig(config)
+}
@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/providers/dns/exec"
+)
+
+func TestNewDNSProvider(t *testing.T) {
+	{
+		// credentials are passed to the provider without changing the environment
+		credentials := map[string]string{"EXEC_PATH": "/usr/local/bin/dns.sh", "EXEC_PROPAGATION_TIMEOUT": "30"}
+		provider, err := NewDNSProvider("exec", credentials)
+		if err != nil {
+			t.Fatalf("Failed to create DNS provider: %v", err)
+		}
+
+		timeout, _ := provider.(*exec.DNSProvider).Timeout()
+		AssertEquals(t, 30*time.Second, timeout)
+		if _, ok := os.LookupEnv("EXEC_PATH"); ok {
+			t.Errorf("Expected credentials not to be exposed as environment variables")
+		}
+	}
+
+	{
+		// required credentials are validated
+		if _, err := NewDNSProvider("hetzner", map[string]string{}); err == nil {
+			t.Errorf("Expected missing credential to be rejected")
+		}
+	}
+
+	{
+		// durations are configured in seconds
+		credentials := map[string]string{"EXEC_PATH": "/usr/local/bin/dns.sh", "EXEC_POLLING_INTERVAL": "5s"}
+		if _, err := NewDNSProvider("exec", credentials); err == nil {
+			t.Errorf("Expected invalid duration to be rejected")
+		}
+	}
+
+	{
+		// unknown providers are rejected
+		if _, err := NewDNSProvider("unknown", nil); err == nil {
+			t.Errorf("Expected unknown provider to be rejected")
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	dockertypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
@@ -22,6 +23,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -84,8 +86,16 @@ type Container struct {
 	ID         string
 	Name       string
 	State      string
+	Health     string // 'starting', 'healthy' or 'unhealthy', empty without health check
 	Endpoint   string
 	ConfigHash string
+
+	// runtime information, only available for inspected containers
+	ImageDigest  string // ID of the image the container was created from
+	StartedAt    time.Time
+	RestartCount int
+	ExitCode     int // exit code of the last run
+	OOMKilled    bool
 }
 
 // IsRunning will check if the current state of the container is marked as 'running'.
@@ -93,6 +103,16 @@ func (c *Container) IsRunning() bool {
 	return c.State == "running"
 }
 
+// Uptime will return the time the container has been running since its last start.
+// It will return zero if the container is not running.
+func (c *Container) Uptime(now time.Time) time.Duration {
+	if !c.IsRunning() || c.StartedAt.IsZero() {
+		return 0
+	}
+
+	return now.Sub(c.StartedAt)
+}
+
 // FindContainer will retrieve the container information for the service with the given name that belongs to the project.
 func (c *Client) FindContainer(ctx context.Context, name string) *Container {
 	inspect, err := c.docker.ContainerInspect(ctx, name)
@@ -101,38 +121,66 @@ func (c *Client) FindContainer(ctx context.Context, name string) *Container {
 		return nil
 	}
 
+	return inspectedContainer(inspect)
+}
+
+// inspectedContainer will create the container information from the inspected container.
+func inspectedContainer(inspect dockertypes.ContainerJSON) *Container {
 	// find FIRST exposed port if any
 	var endpoint string
-	for _, bindings := range inspect.HostConfig.PortBindings {
-		if len(bindings) > 0 {
-			// port is exposed
-			binding := bindings[0]
-			endpoint = fmt.Sprintf("%s:%s", binding.HostIP, binding.HostPort)
-			break // stop at first exposed port
+	if inspect.HostConfig != nil {
+		for _, bindings := range inspect.HostConfig.PortBindings {
+			if len(bindings) > 0 {
+				// port is exposed
+				binding := bindings[0]
+				endpoint = fmt.Sprintf("%s:%s", binding.HostIP, binding.HostPort)
+				break // stop at first exposed port
+			}
 		}
 	}
 
-	return &Container{
-		ID:         inspect.ID,
-		Name:       inspect.Name,
-		State:      inspect.State.Status,
-		ConfigHash: inspect.Config.Labels[LabelHash],
-		Endpoint:   endpoint,
+	cnt := &Container{
+		ID:           inspect.ID,
+		Name:         strings.TrimPrefix(inspect.Name, "/"),
+		ImageDigest:  inspect.Image,
+		RestartCount: inspect.RestartCount,
+		Endpoint:     endpoint,
 	}
+
+	if inspect.Config != nil {
+		cnt.ConfigHash = inspect.Config.Labels[LabelHash]
+	}
+
+	if state := inspect.State; state != nil {
+		cnt.State = state.Status
+		cnt.ExitCode = state.ExitCode
+		cnt.OOMKilled = state.OOMKilled
+		if state.Health != nil {
+			cnt.Health = state.Health.Status
+		}
+
+		// zero time is reported as '0001-01-01T00:00:00Z' for containers that never started
+		if started, err := time.Parse(time.RFC3339Nano, state.StartedAt); err == nil && started.Year() > 1 {
+			cnt.StartedAt = started
+		}
+	}
+
+	return cnt
 }
 
-// CreateContainer will create the container based on the service configuration.
-func (c *Client) CreateContainer(ctx context.Context, service types.Service, net *Network) (*Container, error) {
+// CreateContainer will create the container with the given name based on the service configuration.
+func (c *Client) CreateContainer(ctx context.Context, name string, service types.Service, net *Network) (*Container, error) {
 	err := c.PullImageIfNotExists(ctx, service.ContainerImage, service.Source.Opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to pull image: %w", err)
 	}
 
 	cfg := &container.Config{
-		Image:    service.ContainerImage,
-		Labels:   GenerateServiceLabels(service),
-		Env:      TransformEnvironment(service.Environment),
-		Hostname: service.Name,
+		Image:       service.ContainerImage,
+		Labels:      GenerateServiceLabels(service),
+		Env:         TransformEnvironment(service.Environment),
+		Hostname:    service.Name,
+		Healthcheck: TransformHealthCheck(service.HealthCheck, service.Ingress.ContainerPort),
 	}
 
 	hostCfg := &container.HostConfig{
@@ -192,15 +240,15 @@ func (c *Client) CreateContainer(ctx context.Context, service types.Service, net
 		}
 	}
 
-	c.logger.Tracef("Creating new container with name=%s [ image=%s ]", service.ContainerName, service.ContainerImage)
-	resp, err := c.docker.ContainerCreate(ctx, cfg, hostCfg, nil, nil, service.ContainerName)
+	c.logger.Tracef("Creating new container with name=%s [ image=%s ]", name, service.ContainerImage)
+	resp, err := c.docker.ContainerCreate(ctx, cfg, hostCfg, nil, nil, name)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Container{
 		ID:         resp.ID,
-		Name:       service.ContainerName,
+		Name:       name,
 		State:      "created",
 		Endpoint:   ingress,
 		ConfigHash: service.Hash,
@@ -213,6 +261,12 @@ func (c *Client) StartContainer(ctx context.Context, containerId string) error {
 	return c.docker.ContainerStart(ctx, containerId, container.StartOptions{})
 }
 
+// RenameContainer will change the name of the container with the given ID.
+func (c *Client) RenameContainer(ctx context.Context, containerId string, name string) error {
+	c.logger.Tracef("Renaming container with id=%s to name=%s", containerId, name)
+	return c.docker.ContainerRename(ctx, containerId, name)
+}
+
 func (c *Client) RemoveContainer(ctx context.Context, containerId string) error {
 	c.logger.Tracef("Removing container with id=%s", containerId)
 	return c.docker.ContainerRemove(ctx, containerId, container.RemoveOptions{
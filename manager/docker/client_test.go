@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestInspectedContainer(t *testing.T) {
+	inspect := dockertypes.ContainerJSON{
+		ContainerJSONBase: &dockertypes.ContainerJSONBase{
+			ID:           "0123456789abcdef",
+			Name:         "/default_web",
+			Image:        "sha256:feedface",
+			RestartCount: 2,
+			State: &dockertypes.ContainerState{
+				Status:    "running",
+				OOMKilled: true,
+				ExitCode:  137,
+				StartedAt: "2024-05-01T10:00:00.123456789Z",
+				Health:    &dockertypes.Health{Status: "healthy"},
+			},
+			HostConfig: &container.HostConfig{},
+		},
+		Config: &container.Config{Labels: map[string]string{LabelHash: "hash"}},
+	}
+
+	c := inspectedContainer(inspect)
+	if c.Name != "default_web" || c.ImageDigest != "sha256:feedface" || c.ConfigHash != "hash" {
+		t.Errorf("unexpected container information: %+v", c)
+	}
+	if c.RestartCount != 2 || c.ExitCode != 137 || !c.OOMKilled || c.Health != "healthy" {
+		t.Errorf("unexpected runtime information: %+v", c)
+	}
+
+	started := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	if !c.StartedAt.Equal(started) {
+		t.Errorf("expected start time %s, got %s", started, c.StartedAt)
+	}
+	if uptime := c.Uptime(started.Add(time.Hour)); uptime != time.Hour {
+		t.Errorf("expected uptime of one hour, got %s", uptime)
+	}
+
+	// containers that never started have no start time
+	inspect.State = &dockertypes.ContainerState{Status: "created", StartedAt: "0001-01-01T00:00:00Z"}
+	c = inspectedContainer(inspect)
+	if !c.StartedAt.IsZero() || c.Uptime(time.Now()) != 0 {
+		t.Errorf("expected no start time for created container, got %s", c.StartedAt)
+	}
+}
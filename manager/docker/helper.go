@@ -2,6 +2,7 @@ package docker
 
 import (
 	"fmt"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/jorenkoyen/conter/manager/types"
 	"net"
@@ -44,6 +45,22 @@ func TransformEnvironment(env map[string]string) []string {
 	return output
 }
 
+// TransformHealthCheck will transform the health check of the service into the docker health configuration.
+// The port is the container port the HTTP health check is requested on.
+func TransformHealthCheck(check *types.ContainerHealthCheck, port int) *container.HealthConfig {
+	if check == nil {
+		return nil
+	}
+
+	return &container.HealthConfig{
+		Test:        check.Test(port),
+		Interval:    check.IntervalDuration(),
+		Timeout:     check.TimeoutDuration(),
+		Retries:     check.RetryCount(),
+		StartPeriod: check.StartPeriodDuration(),
+	}
+}
+
 // GetAvailablePort finds the next available port within a specified range.
 func GetAvailablePort(start, end int) int {
 	for port := start; port <= end; port++ {
@@ -1,6 +1,12 @@
 package docker
 
-import "testing"
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jorenkoyen/conter/manager/types"
+)
 
 func TestToBytes(t *testing.T) {
 
@@ -9,3 +15,31 @@ func TestToBytes(t *testing.T) {
 		t.Fatalf("expected 128000000 but got %d", bytes)
 	}
 }
+
+func TestTransformHealthCheck(t *testing.T) {
+	if TransformHealthCheck(nil, 80) != nil {
+		t.Fatalf("expected no health config without health check")
+	}
+
+	{
+		// command is executed directly with defaults applied
+		cfg := TransformHealthCheck(&types.ContainerHealthCheck{Command: []string{"pg_isready", "-q"}}, 0)
+		if strings.Join(cfg.Test, " ") != "CMD pg_isready -q" {
+			t.Errorf("unexpected test for command: %v", cfg.Test)
+		}
+		if cfg.Interval != 30*time.Second || cfg.Timeout != 5*time.Second || cfg.Retries != 3 || cfg.StartPeriod != 0 {
+			t.Errorf("unexpected defaults: %+v", cfg)
+		}
+	}
+
+	{
+		// path is requested on the container port
+		cfg := TransformHealthCheck(&types.ContainerHealthCheck{Path: "health", Interval: 10, Retries: 5, StartPeriod: 60}, 8080)
+		if cfg.Test[0] != "CMD-SHELL" || !strings.Contains(cfg.Test[1], "http://localhost:8080/health") {
+			t.Errorf("unexpected test for path: %v", cfg.Test)
+		}
+		if cfg.Interval != 10*time.Second || cfg.Retries != 5 || cfg.StartPeriod != time.Minute {
+			t.Errorf("unexpected configuration: %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+type ExportFormat string
+
+const (
+	ExportFormatPEM    ExportFormat = "pem"
+	ExportFormatPKCS12 ExportFormat = "pkcs12"
+)
+
+// Export will return the certificate chain and private key for the domain in the requested format.
+// The domain must match the certificate exactly, like Revoke a wildcard certificate is only exported using its wildcard domain.
+// The password is only used to protect PKCS#12 archives.
+func (c *CertificateManager) Export(domain string, format ExportFormat, password string) ([]byte, error) {
+	cert := c.get(domain)
+	if cert == nil {
+		return nil, db.ErrItemNotFound
+	}
+
+	chain, err := cert.CertificateBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode certificate: %w", err)
+	}
+
+	key, err := cert.PrivateKeyBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %w", err)
+	}
+
+	switch format {
+	case ExportFormatPEM, "":
+		bundle := bytes.TrimRight(chain, "\n")
+		bundle = append(bundle, '\n')
+		return append(bundle, key...), nil
+
+	case ExportFormatPKCS12:
+		certificates, err := certcrypto.ParsePEMBundle(chain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+
+		privateKey, err := certcrypto.ParsePEMPrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+
+		return pkcs12.Modern.Encode(privateKey, certificates[0], certificates[1:], password)
+
+	default:
+		return nil, fmt.Errorf("export format=%s is not supported", format)
+	}
+}
+
+// Revoke will revoke the certificate for the domain with the ACME server and remove it from the system.
+// The domain must match the certificate exactly, the wildcard certificate covering the domain is never revoked instead.
+// Certificates that are not issued through ACME are only removed.
+func (c *CertificateManager) Revoke(domain string) error {
+	cert := c.get(domain)
+	if cert == nil {
+		return db.ErrItemNotFound
+	}
+
+	if isAcmeChallenge(cert.ChallengeType) {
+		iss := c.issuer(cert.Issuer)
+		if iss == nil {
+			return fmt.Errorf("unable to revoke certificate, issuer=%s is not configured", cert.Issuer)
+		}
+
+		client := iss.anyClient(cert.ChallengeType)
+		if client == nil {
+			return fmt.Errorf("unable to revoke certificate, issuer=%s has no ACME client", iss.name)
+		}
+
+		raw, err := cert.CertificateBytes()
+		if err != nil {
+			return fmt.Errorf("failed to decode certificate: %w", err)
+		}
+
+		c.logger.Infof("Revoking certificate with id=%s (domains=%v)", cert.ID, cert.Domains)
+		if err = client.Certificate.Revoke(raw); err != nil {
+			return fmt.Errorf("failed to revoke certificate: %w", err)
+		}
+	}
+
+	c.logger.Infof("Removing certificate with id=%s (domains=%v)", cert.ID, cert.Domains)
+	return c.data.RemoveCertificate(cert)
+}
+
+// isAcmeChallenge will return true if certificates of the challenge type are issued by an ACME server.
+func isAcmeChallenge(challenge types.ChallengeType) bool {
+	return challenge == types.ChallengeTypeHTTP || challenge == types.ChallengeTypeDNS || challenge == types.ChallengeTypeTLS
+}
@@ -0,0 +1,103 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jorenkoyen/conter/manager/db"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestCertificateManager_Export(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	cert, key := createTestCertificate(t, time.Now().Add(time.Hour), "www.example.com")
+	if _, err := mgr.Import(ImportCertificateOptions{Certificate: cert, Key: key}); err != nil {
+		t.Fatalf("Failed to import certificate: %v", err)
+	}
+
+	{
+		// PEM bundle contains the chain and the key
+		content, err := mgr.Export("www.example.com", ExportFormatPEM, "")
+		if err != nil {
+			t.Fatalf("Failed to export PEM bundle: %v", err)
+		}
+
+		if !bytes.Contains(content, []byte(cert)) || !bytes.Contains(content, []byte(key)) {
+			t.Errorf("Expected PEM bundle to contain the certificate and key")
+		}
+	}
+
+	{
+		// PKCS#12 archive is protected with the password
+		content, err := mgr.Export("www.example.com", ExportFormatPKCS12, "secret")
+		if err != nil {
+			t.Fatalf("Failed to export PKCS#12 archive: %v", err)
+		}
+
+		_, leaf, _, err := pkcs12.DecodeChain(content, "secret")
+		if err != nil {
+			t.Fatalf("Failed to decode PKCS#12 archive: %v", err)
+		}
+		AssertEquals(t, "www.example.com", leaf.Subject.CommonName)
+	}
+
+	{
+		// unknown format
+		if _, err := mgr.Export("www.example.com", "der", ""); err == nil {
+			t.Errorf("Expected an error for an unsupported format")
+		}
+	}
+
+	{
+		// unknown domain
+		if _, err := mgr.Export("unknown.example.com", ExportFormatPEM, ""); !errors.Is(err, db.ErrItemNotFound) {
+			t.Errorf("Expected not found error, got: %v", err)
+		}
+	}
+
+	{
+		// wildcard certificates are only exported using the wildcard domain
+		cert, key = createTestCertificate(t, time.Now().Add(time.Hour), "*.example.org")
+		if _, err := mgr.Import(ImportCertificateOptions{Certificate: cert, Key: key}); err != nil {
+			t.Fatalf("Failed to import wildcard certificate: %v", err)
+		}
+
+		if _, err := mgr.Export("www.example.org", ExportFormatPEM, ""); !errors.Is(err, db.ErrItemNotFound) {
+			t.Errorf("Expected not found error for a domain covered by a wildcard, got: %v", err)
+		}
+
+		if _, err := mgr.Export("*.example.org", ExportFormatPEM, ""); err != nil {
+			t.Errorf("Failed to export wildcard certificate: %v", err)
+		}
+	}
+}
+
+func TestCertificateManager_Revoke(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	cert, key := createTestCertificate(t, time.Now().Add(time.Hour), "www.example.com", "example.com")
+	if _, err := mgr.Import(ImportCertificateOptions{Certificate: cert, Key: key}); err != nil {
+		t.Fatalf("Failed to import certificate: %v", err)
+	}
+
+	// imported certificates are only removed, they are not known by the ACME server
+	if err := mgr.Revoke("www.example.com"); err != nil {
+		t.Fatalf("Failed to revoke certificate: %v", err)
+	}
+
+	if mgr.Get("www.example.com") != nil || mgr.Get("example.com") != nil {
+		t.Errorf("Expected certificate to be removed for all domains")
+	}
+	AssertEquals(t, 0, len(mgr.GetAll()))
+
+	if err := mgr.Revoke("www.example.com"); !errors.Is(err, db.ErrItemNotFound) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package manager
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"slices"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/google/uuid"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+// ImportCertificateOptions contains the user-provided certificate that should be imported.
+type ImportCertificateOptions struct {
+	Certificate string   `json:"certificate"` // PEM encoded certificate chain, leaf first
+	Key         string   `json:"key"`         // PEM encoded private key
+	Domains     []string `json:"domains"`     // defaults to the DNS names of the certificate
+}
+
+// Import will validate the user-provided certificate and store it for the requested domains.
+// Imported certificates use challenge type MANUAL and are never renewed automatically.
+// They are kept until revoked, also when none of the domains has a route.
+func (c *CertificateManager) Import(opts ImportCertificateOptions) (*types.Certificate, error) {
+	err := new(types.ValidationError)
+
+	chain, parseErr := certcrypto.ParsePEMBundle([]byte(opts.Certificate))
+	if parseErr != nil {
+		err.Appendf("certificate", "Invalid PEM certificate chain: %v", parseErr)
+	}
+
+	if _, keyErr := certcrypto.ParsePEMPrivateKey([]byte(opts.Key)); keyErr != nil {
+		err.Appendf("key", "Invalid PEM private key: %v", keyErr)
+	}
+
+	if err.HasFailures() {
+		return nil, err
+	}
+
+	if _, pairErr := tls.X509KeyPair([]byte(opts.Certificate), []byte(opts.Key)); pairErr != nil {
+		err.Appendf("key", "Private key does not match the certificate: %v", pairErr)
+		return nil, err
+	}
+
+	leaf := chain[0]
+	if time.Now().After(leaf.NotAfter) {
+		err.Appendf("certificate", "Certificate expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	domains := opts.Domains
+	if len(domains) == 0 {
+		domains = leaf.DNSNames
+	}
+
+	if len(domains) == 0 {
+		err.Append("domains", "Certificate does not contain any DNS names")
+	}
+
+	for _, domain := range domains {
+		if !certificateCovers(leaf.DNSNames, domain) {
+			err.Appendf("domains", "Certificate is not valid for domain=%s", domain)
+		}
+	}
+
+	if err.HasFailures() {
+		return nil, err
+	}
+
+	cert := &types.Certificate{
+		ID:            uuid.NewString(),
+		Certificate:   base64.StdEncoding.EncodeToString([]byte(opts.Certificate)),
+		Key:           base64.StdEncoding.EncodeToString([]byte(opts.Key)),
+		ChallengeType: types.ChallengeTypeManual,
+		Domains:       domains,
+	}
+
+	c.logger.Infof("Importing certificate with id=%s (domains=%v, expiry=%s)", cert.ID, domains, leaf.NotAfter.String())
+	if saveErr := c.data.SetCertificate(cert); saveErr != nil {
+		return nil, saveErr
+	}
+
+	return cert, nil
+}
+
+// certificateCovers will return true if the DNS names of a certificate cover the domain, including wildcard names.
+func certificateCovers(names []string, domain string) bool {
+	return slices.Contains(names, domain) || slices.Contains(names, types.WildcardOf(domain))
+}
@@ -0,0 +1,138 @@
+package manager
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+// createTestCertificate will create a self-signed PEM certificate and key for the DNS names.
+func createTestCertificate(t *testing.T, notAfter time.Time, names ...string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     names,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	raw, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: raw})
+	return string(certPEM), string(keyPEM)
+}
+
+func TestCertificateManager_Import(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	valid := time.Now().Add(90 * 24 * time.Hour)
+
+	{
+		// domains default to the names of the certificate
+		cert, key := createTestCertificate(t, valid, "www.example.com", "example.com")
+		imported, err := mgr.Import(ImportCertificateOptions{Certificate: cert, Key: key})
+		if err != nil {
+			t.Fatalf("Expected certificate to be imported: %v", err)
+		}
+
+		AssertEquals(t, types.ChallengeTypeManual, imported.ChallengeType)
+		AssertEquals(t, 2, len(imported.Domains))
+		if mgr.Get("example.com") == nil {
+			t.Errorf("Expected imported certificate to be served for example.com")
+		}
+	}
+
+	{
+		// wildcard certificate covers subdomains
+		cert, key := createTestCertificate(t, valid, "*.customers.example.com")
+		if _, err := mgr.Import(ImportCertificateOptions{Certificate: cert, Key: key, Domains: []string{"acme.customers.example.com"}}); err != nil {
+			t.Errorf("Expected wildcard certificate to cover the domain: %v", err)
+		}
+	}
+
+	{
+		// certificate not valid for requested domain
+		cert, key := createTestCertificate(t, valid, "www.example.com")
+		_, err := mgr.Import(ImportCertificateOptions{Certificate: cert, Key: key, Domains: []string{"other.example.com"}})
+		AssertErrorThrownForField(t, asValidationError(t, err), "domains")
+	}
+
+	{
+		// private key of another certificate
+		cert, _ := createTestCertificate(t, valid, "www.example.com")
+		_, key := createTestCertificate(t, valid, "www.example.com")
+		_, err := mgr.Import(ImportCertificateOptions{Certificate: cert, Key: key})
+		AssertErrorThrownForField(t, asValidationError(t, err), "key")
+	}
+
+	{
+		// expired certificate
+		cert, key := createTestCertificate(t, time.Now().Add(-time.Minute), "www.example.com")
+		_, err := mgr.Import(ImportCertificateOptions{Certificate: cert, Key: key})
+		AssertErrorThrownForField(t, asValidationError(t, err), "certificate")
+	}
+
+	{
+		// not PEM encoded
+		_, err := mgr.Import(ImportCertificateOptions{Certificate: "invalid", Key: "invalid"})
+		AssertErrorThrownForField(t, asValidationError(t, err), "certificate")
+	}
+}
+
+func TestCertificateManager_Import_retained(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	// the certificate is imported before the route of the domain exists
+	cert, key := createTestCertificate(t, time.Now().Add(90*24*time.Hour), "www.example.com")
+	imported, err := mgr.Import(ImportCertificateOptions{Certificate: cert, Key: key})
+	if err != nil {
+		t.Fatalf("Expected certificate to be imported: %v", err)
+	}
+
+	if err = mgr.Batch(); err != nil {
+		t.Fatalf("Failed to run batch: %v", err)
+	}
+
+	stored := mgr.Get("www.example.com")
+	if stored == nil {
+		t.Fatalf("Expected imported certificate to be kept without a route")
+	}
+	AssertEquals(t, imported.ID, stored.ID)
+}
+
+func asValidationError(t *testing.T, err error) *types.ValidationError {
+	t.Helper()
+	validation, ok := err.(*types.ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error, got: %v", err)
+	}
+	return validation
+}
@@ -8,10 +8,15 @@ import (
 	"github.com/jorenkoyen/go-logger"
 	"github.com/jorenkoyen/go-logger/log"
 	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type IngressManager struct {
 	logger             *logger.Logger
+	table              atomic.Pointer[RouteTable]
+	mutex              sync.Mutex
 	Database           *db.Client
 	CertificateManager *CertificateManager
 }
@@ -24,30 +29,23 @@ func NewIngressManager() *IngressManager {
 }
 
 // RegisterRoute will register a new ingress route and complete the necessary actions to make it ready for use.
+// Failing to request the certificates does not fail the registration, the route is in use once it is saved.
 func (i *IngressManager) RegisterRoute(ingress types.Ingress) error {
 	if len(ingress.Domains) == 0 {
 		i.logger.Debugf("No action required when registering routes for service=%s, not exposed", ingress.TargetService)
 		return nil
 	}
 
-	if ingress.TargetEndpoint == "" {
+	if len(ingress.TargetEndpoints) == 0 {
 		return errors.New("no endpoint available for registering route")
 	}
 
-	i.logger.Debugf("Registering route for %s (endpoint=%s, challenge=%s)", ingress.String(), ingress.TargetEndpoint, ingress.ChallengeType)
+	i.logger.Debugf("Registering route for %s (endpoints=%s, challenge=%s)", ingress.String(), strings.Join(ingress.TargetEndpoints, ","), ingress.ChallengeType)
 
 	// check all domains linked to ingress
-	//	-> fail if any exist that are not for the same project / service.
-	for _, domain := range ingress.Domains {
-		match, err := i.Match(domain)
-		if err == nil {
-			if match.TargetProject != ingress.TargetProject {
-				return fmt.Errorf("domain=%s is already used for project=%s", domain, match.TargetProject)
-			}
-			if match.TargetService != ingress.TargetService {
-				return fmt.Errorf("domain=%s is already used for service=%s", domain, match.TargetService)
-			}
-		}
+	//	-> fail if any route overlaps that is not for the same project / service.
+	if err := i.checkConflicts(ingress); err != nil {
+		return err
 	}
 
 	// save ingress routes
@@ -57,15 +55,37 @@ func (i *IngressManager) RegisterRoute(ingress types.Ingress) error {
 		return fmt.Errorf("failed to save ingress route: %w", err)
 	}
 
-	if i.CertificateManager.HasValidCertificate(ingress.Domains) {
+	if i.CertificateManager.HasValidCertificate(ingress.Domains) && i.CertificateManager.hasKeyType(ingress.Domains, ingress.ChallengeType, ingress.KeyOptions()) {
 		i.logger.Infof("Not requesting certificates for %s, already has valid certificates", ingress.String())
 		return nil
 	} else {
 		// start certificate creation
 		i.logger.Infof("Requesting certificates for %s", ingress.String())
-		err = i.CertificateManager.ChallengeCreate(ingress.Domains, ingress.ChallengeType)
+		err = i.CertificateManager.ChallengeCreate(ingress.Domains, ingress.ChallengeType, ingress.KeyOptions())
 		if err != nil {
-			return fmt.Errorf("failed to create certificates for %s", ingress.String())
+			// the route is already serving the new endpoints, failed orders are retried in the background
+			i.logger.Errorf("Failed to request certificates for %s: %v", ingress.String(), err)
+		}
+	}
+
+	return nil
+}
+
+// checkConflicts will return an error when the ingress clashes with a route registered by another project or service.
+func (i *IngressManager) checkConflicts(ingress types.Ingress) error {
+	for _, domain := range ingress.Domains {
+		for _, route := range i.Database.GetIngressRoutesByDomain(domain) {
+			if route.PathPrefix() != ingress.PathPrefix() {
+				continue // routed by the longest matching prefix
+			}
+
+			if route.TargetProject != ingress.TargetProject {
+				return fmt.Errorf("domain=%s with path=%s is already used by project=%s", domain, ingress.PathPrefix(), route.TargetProject)
+			}
+
+			if route.TargetService != ingress.TargetService {
+				return fmt.Errorf("domain=%s with path=%s is already used for service=%s", domain, ingress.PathPrefix(), route.TargetService)
+			}
 		}
 	}
 
@@ -73,18 +93,19 @@ func (i *IngressManager) RegisterRoute(ingress types.Ingress) error {
 }
 
 // RemoveUnusedRoutes will remove all unused routes related to the specified project.
-func (i *IngressManager) RemoveUnusedRoutes(project string, excludedDomains []string) (int, error) {
-	i.logger.Tracef("Removing unused routes for project=%s (excluded=%s)", project, excludedDomains)
+// The excluded routes are identified by their route key (see [types.RouteKey]).
+func (i *IngressManager) RemoveUnusedRoutes(project string, excludedRoutes []string) (int, error) {
+	i.logger.Tracef("Removing unused routes for project=%s (excluded=%s)", project, excludedRoutes)
 
 	routes := i.Database.GetIngressRoutesByProject(project)
 	removed := 0
-	for domain, _ := range routes {
-		if len(excludedDomains) == 0 || slices.Index(excludedDomains, domain) == -1 {
-			// not listed in excluded domains -> remove
-			i.logger.Debugf("Removing unused route for domain=%s linked to project=%s", domain, project)
-			err := i.Database.RemoveIngressRoute(domain)
+	for key := range routes {
+		if len(excludedRoutes) == 0 || slices.Index(excludedRoutes, key) == -1 {
+			// not listed in excluded routes -> remove
+			i.logger.Debugf("Removing unused route=%s linked to project=%s", key, project)
+			err := i.Database.RemoveIngressRoute(key)
 			if err != nil {
-				return removed, fmt.Errorf("failed to remove %s: %w", domain, err)
+				return removed, fmt.Errorf("failed to remove %s: %w", key, err)
 			}
 
 			removed++
@@ -96,10 +117,47 @@ func (i *IngressManager) RemoveUnusedRoutes(project string, excludedDomains []st
 
 // RemoveAllRoutes will remove all routes linked to the specified project.
 func (i *IngressManager) RemoveAllRoutes(project string) (int, error) {
-	return i.RemoveUnusedRoutes(project, nil) // no excluded domains
+	return i.RemoveUnusedRoutes(project, nil) // no excluded routes
+}
+
+// Rebuild will reload the in-memory route table from the database.
+// It should be called every time the routes in the database have been modified.
+func (i *IngressManager) Rebuild() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	table := NewRouteTable(i.Database.GetAllIngressRoutes())
+	i.table.Store(table)
+	i.logger.Tracef("Rebuilt route table with %d routes", len(table.Routes()))
+}
+
+// routes will return the current route table, it will be loaded on first use.
+func (i *IngressManager) routes() *RouteTable {
+	table := i.table.Load()
+	if table == nil {
+		i.Rebuild()
+		table = i.table.Load()
+	}
+	return table
+}
+
+// GetAllRoutes will return all ingress routes currently known to the system.
+func (i *IngressManager) GetAllRoutes() []types.Ingress {
+	return i.routes().Routes()
 }
 
-// Match will retrieve the ingress route information for the specified domain.
-func (i *IngressManager) Match(domain string) (*types.Ingress, error) {
-	return i.Database.GetIngressRoute(domain)
+// IsRouted will return true if traffic for the domain can be routed, either by its own route or by a wildcard route.
+func (i *IngressManager) IsRouted(domain string) bool {
+	return i.routes().HasDomain(domain)
+}
+
+// Match will retrieve the ingress route information for the specified domain and request path.
+// When multiple routes are registered for the domain the route with the longest matching path prefix wins.
+func (i *IngressManager) Match(domain string, path string) (*types.Ingress, error) {
+	match := i.routes().Match(domain, path)
+	if match == nil {
+		return nil, db.ErrItemNotFound
+	}
+
+	return match, nil
 }
@@ -0,0 +1,297 @@
+package manager
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+	"github.com/jorenkoyen/conter/version"
+)
+
+// IssuerOptions configure an ACME certificate authority used for issuing certificates.
+type IssuerOptions struct {
+	Name      string
+	Directory string
+	// EABKeyID and EABHMACKey are the External Account Binding credentials required by some ACME servers (e.g. ZeroSSL).
+	EABKeyID   string
+	EABHMACKey string
+}
+
+// issuer is an ACME certificate authority with a registered account.
+type issuer struct {
+	name      string
+	directory string
+	config    *db.Config
+	providers map[types.ChallengeType]func(client *lego.Client) error // configures the client of the challenge type
+
+	mutex   sync.RWMutex            // guards the user and clients, they are replaced when the account changes
+	user    *types.AcmeRegistration // never modified once set, changes are made on a copy
+	clients map[types.ChallengeType]*lego.Client
+}
+
+// currentUser will return the ACME user of the issuer.
+// The user must not be modified, use setUser with a modified copy instead.
+func (iss *issuer) currentUser() *types.AcmeRegistration {
+	iss.mutex.RLock()
+	defer iss.mutex.RUnlock()
+	return iss.user
+}
+
+// setUser will replace the ACME user of the issuer.
+func (iss *issuer) setUser(user *types.AcmeRegistration) {
+	iss.mutex.Lock()
+	defer iss.mutex.Unlock()
+	iss.user = user
+}
+
+// client will return the ACME client solving the challenge type.
+// It will return nil if the challenge type is not enabled for the issuer.
+func (iss *issuer) client(challenge types.ChallengeType) *lego.Client {
+	iss.mutex.RLock()
+	defer iss.mutex.RUnlock()
+	return iss.clients[challenge]
+}
+
+// supports will return true if the challenge type is enabled for the issuer.
+func (iss *issuer) supports(challenge types.ChallengeType) bool {
+	iss.mutex.RLock()
+	defer iss.mutex.RUnlock()
+	_, ok := iss.clients[challenge]
+	return ok
+}
+
+// anyClient will return the ACME client of the challenge type, or the HTTP-01 client when it is not enabled.
+// It is used for requests that do not solve a challenge, e.g. revoking a certificate.
+func (iss *issuer) anyClient(challenge types.ChallengeType) *lego.Client {
+	if client := iss.client(challenge); client != nil {
+		return client
+	}
+	return iss.client(types.ChallengeTypeHTTP)
+}
+
+// register will register the user with the ACME issuer, if the user is already registered it will only validate the registration.
+// The HTTP-01 and TLS-ALPN-01 challenges are enabled for the issuer.
+func (c *CertificateManager) register(config *db.Config, opts IssuerOptions, email string, isRetry bool) (*issuer, error) {
+	privateKey, err := config.GetAcmePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &types.AcmeRegistration{
+		Email:        config.GetAcmeEmail(),
+		PrivateKey:   privateKey,
+		Registration: config.GetAcmeRegistration(),
+	}
+
+	if user.IsValid() && user.Email != email && opts.Directory == config.GetAcmeDirectory() {
+		// the account is only changed on request of the user, see UpdateAccount
+		c.logger.Warningf("ACME account for issuer=%s is registered with email=%s instead of email=%s, use 'conctl acme update-email' to change it", opts.Name, user.Email, email)
+	}
+
+	if !user.IsValid() || opts.Directory != config.GetAcmeDirectory() {
+		c.logger.Infof("Initializing ACME user for email=%s (issuer=%s)", email, opts.Name)
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			c.logger.Fatalf("Failed to generate private key: %v", err)
+		}
+
+		user = &types.AcmeRegistration{
+			Email:      email,
+			PrivateKey: privateKey,
+		}
+	}
+
+	iss := &issuer{
+		name:      opts.Name,
+		directory: opts.Directory,
+		config:    config,
+		user:      user,
+		clients:   make(map[types.ChallengeType]*lego.Client),
+		providers: make(map[types.ChallengeType]func(client *lego.Client) error),
+	}
+
+	// continue LEGO configuration
+	client, err := c.newClient(iss, user)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create LEGO client: %w", err)
+	}
+
+	// check if we require registration
+	if user.GetRegistration() == nil {
+		var reg *registration.Resource
+		if opts.EABKeyID != "" {
+			reg, err = client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+				TermsOfServiceAgreed: true,
+				Kid:                  opts.EABKeyID,
+				HmacEncoded:          opts.EABHMACKey,
+			})
+		} else {
+			reg, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to register with ACME: %w", err)
+		}
+
+		c.logger.Debugf("Successfully registered with ACME registry (uri=%s, issuer=%s)", reg.URI, opts.Name)
+		user.Registration = reg
+
+		// persist all information
+		config.SetAcmeEmail(user.Email)
+		config.SetAcmePrivateKey(user.PrivateKey)
+		config.SetAcmeRegistration(user.Registration)
+		config.SetAcmeDirectory(opts.Directory) // persist directory URL to compare registration
+	} else {
+		// validate registration
+		reg, err := client.Registration.QueryRegistration()
+		if err != nil {
+			c.logger.Errorf("Failed to query current ACME registration: %v (issuer=%s)", err, opts.Name)
+			config.ClearAcme() // clear ACME as it is no longer valid.
+			if isRetry {
+				return nil, errors.New("ACME registration is not valid")
+			}
+
+			// retry client initialization
+			return c.register(config, opts, email, true)
+		}
+
+		c.logger.Tracef("Current active ACME registration on uri=%s (issuer=%s)", reg.URI, opts.Name)
+	}
+
+	// set challenge providers
+	err = c.enable(iss, types.ChallengeTypeHTTP, func(client *lego.Client) error {
+		return client.Challenge.SetHTTP01Provider(c)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register HTTP-01 provider: %w", err)
+	}
+
+	// the TLS-ALPN-01 challenge is stored the same way as HTTP-01, only the way it is served differs
+	err = c.enable(iss, types.ChallengeTypeTLS, func(client *lego.Client) error {
+		return client.Challenge.SetTLSALPN01Provider(c)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register TLS-ALPN-01 provider: %w", err)
+	}
+
+	return iss, nil
+}
+
+// enable will create the ACME client solving the challenge type for the issuer.
+// The provider configures the client and is kept so the client can be recreated when the account changes.
+func (c *CertificateManager) enable(iss *issuer, challenge types.ChallengeType, provider func(client *lego.Client) error) error {
+	client, err := c.newClient(iss, iss.currentUser())
+	if err != nil {
+		return fmt.Errorf("unable to create LEGO client: %w", err)
+	}
+
+	if err = provider(client); err != nil {
+		return err
+	}
+
+	iss.mutex.Lock()
+	defer iss.mutex.Unlock()
+	iss.clients[challenge] = client
+	iss.providers[challenge] = provider
+	return nil
+}
+
+// reconnect will recreate all ACME clients of the issuer for the user, required after the account key has changed.
+// The user and clients of the issuer are only replaced when all clients have been created.
+func (c *CertificateManager) reconnect(iss *issuer, user *types.AcmeRegistration) error {
+	iss.mutex.RLock()
+	providers := maps.Clone(iss.providers)
+	iss.mutex.RUnlock()
+
+	clients := make(map[types.ChallengeType]*lego.Client, len(providers))
+	for challenge, provider := range providers {
+		client, err := c.newClient(iss, user)
+		if err != nil {
+			return fmt.Errorf("unable to create LEGO client: %w", err)
+		}
+
+		if err = provider(client); err != nil {
+			return fmt.Errorf("failed to configure challenge=%s: %w", challenge, err)
+		}
+
+		clients[challenge] = client
+	}
+
+	iss.mutex.Lock()
+	defer iss.mutex.Unlock()
+	iss.user = user
+	iss.clients = clients
+	return nil
+}
+
+// newClient will create a new ACME client for the user of the issuer without any challenge providers.
+func (c *CertificateManager) newClient(iss *issuer, user *types.AcmeRegistration) (*lego.Client, error) {
+	config := lego.NewConfig(user)
+	config.CADirURL = iss.directory
+	config.UserAgent = userAgent()
+	config.HTTPClient = c.httpClient()
+
+	c.logger.Tracef("Creating ACME client for directory=%s (email=%s)", config.CADirURL, user.Email)
+	return lego.NewClient(config)
+}
+
+// httpClient will create the HTTP client used for communicating with ACME servers.
+func (c *CertificateManager) httpClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: c.insecure,
+			},
+		},
+	}
+}
+
+// userAgent will return the user agent used for communicating with ACME servers.
+func userAgent() string {
+	return fmt.Sprintf("conter/%s", version.Version)
+}
+
+// issuersFor will return all issuers, in order of preference, that are able to solve the challenge type.
+func (c *CertificateManager) issuersFor(challenge types.ChallengeType) []*issuer {
+	output := make([]*issuer, 0)
+	for _, iss := range c.activeIssuers() {
+		if iss.supports(challenge) {
+			output = append(output, iss)
+		}
+	}
+	return output
+}
+
+// activeIssuers will return a snapshot of all issuers in order of preference.
+func (c *CertificateManager) activeIssuers() []*issuer {
+	c.issuersMutex.RLock()
+	defer c.issuersMutex.RUnlock()
+	return slices.Clone(c.issuers)
+}
+
+// issuer will return the issuer with the given name, certificates without issuer belong to the preferred issuer.
+// It will return nil if the issuer is not configured.
+func (c *CertificateManager) issuer(name string) *issuer {
+	issuers := c.activeIssuers()
+	for _, iss := range issuers {
+		if iss.name == name {
+			return iss
+		}
+	}
+
+	if name == "" && len(issuers) > 0 {
+		return issuers[0]
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+func TestCertificateManager_issuersFor(t *testing.T) {
+	mgr := &CertificateManager{issuers: []*issuer{
+		{name: "primary", clients: map[types.ChallengeType]*lego.Client{types.ChallengeTypeHTTP: nil, types.ChallengeTypeTLS: nil}},
+		{name: "secondary", clients: map[types.ChallengeType]*lego.Client{types.ChallengeTypeHTTP: nil, types.ChallengeTypeDNS: nil}},
+	}}
+
+	// issuers are returned in order of preference
+	http := mgr.issuersFor(types.ChallengeTypeHTTP)
+	AssertEquals(t, 2, len(http))
+	AssertEquals(t, "primary", http[0].name)
+	AssertEquals(t, "secondary", http[1].name)
+
+	dns := mgr.issuersFor(types.ChallengeTypeDNS)
+	AssertEquals(t, 1, len(dns))
+	AssertEquals(t, "secondary", dns[0].name)
+
+	AssertEquals(t, 0, len(mgr.issuersFor(types.ChallengeTypeManual)))
+
+	// certificates without issuer belong to the preferred issuer
+	AssertEquals(t, "primary", mgr.issuer("").name)
+	AssertEquals(t, "secondary", mgr.issuer("secondary").name)
+	if mgr.issuer("unknown") != nil {
+		t.Errorf("Expected no issuer for an unknown name")
+	}
+}
@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+// DefaultKeyType is the key type used for requesting certificates when none is configured.
+const DefaultKeyType = types.KeyTypeRSA2048
+
+// SupportedKeyTypes are the key types that can be used for requesting certificates.
+var SupportedKeyTypes = []types.KeyType{
+	types.KeyTypeEC256,
+	types.KeyTypeEC384,
+	types.KeyTypeRSA2048,
+	types.KeyTypeRSA4096,
+}
+
+var certcryptoKeyTypes = map[types.KeyType]certcrypto.KeyType{
+	types.KeyTypeEC256:   certcrypto.EC256,
+	types.KeyTypeEC384:   certcrypto.EC384,
+	types.KeyTypeRSA2048: certcrypto.RSA2048,
+	types.KeyTypeRSA4096: certcrypto.RSA4096,
+}
+
+// keyOptions will complete the key settings with the defaults of the daemon.
+// The key is reused when either the daemon or the requested settings ask for it.
+func (c *CertificateManager) keyOptions(opts types.KeyOptions) types.KeyOptions {
+	if opts.Type == "" {
+		opts.Type = c.KeyType
+	}
+
+	if opts.Type == "" {
+		opts.Type = DefaultKeyType
+	}
+
+	opts.Reuse = opts.Reuse || c.ReuseKey
+	return opts
+}
+
+// hasKeyType will return true if the certificates of the domains use the requested key type.
+// Only certificates issued through ACME or the internal certificate authority are checked, other certificates can not be requested with a key type.
+func (c *CertificateManager) hasKeyType(domains []string, challenge types.ChallengeType, key types.KeyOptions) bool {
+	if !isAcmeChallenge(challenge) && challenge != types.ChallengeTypeInternal {
+		return true
+	}
+
+	keyType := c.keyOptions(key).Type
+	for _, domain := range domains {
+		cert := c.get(domain)
+		if cert == nil {
+			return false
+		}
+
+		info, err := cert.Parse()
+		if err != nil || types.KeyTypeOf(info.PublicKey) != keyType {
+			return false
+		}
+	}
+
+	return true
+}
+
+// privateKey will return the private key used for issuing a certificate with the resolved key settings.
+// The key of the existing certificate is reused when requested and it matches the key type.
+func (c *CertificateManager) privateKey(opts types.KeyOptions, existing *types.Certificate) (crypto.PrivateKey, error) {
+	keyType, ok := certcryptoKeyTypes[opts.Type]
+	if !ok {
+		return nil, fmt.Errorf("key type=%s is not supported", opts.Type)
+	}
+
+	if opts.Reuse && existing != nil {
+		if key, err := reusableKey(existing, opts.Type); err != nil {
+			c.logger.Warningf("Not reusing private key of certificate with id=%s: %v", existing.ID, err)
+		} else {
+			c.logger.Debugf("Reusing private key of certificate with id=%s (domains=%v)", existing.ID, existing.Domains)
+			return key, nil
+		}
+	}
+
+	return certcrypto.GeneratePrivateKey(keyType)
+}
+
+// reusableKey will return the private key of the certificate if it is of the key type.
+func reusableKey(cert *types.Certificate, keyType types.KeyType) (crypto.PrivateKey, error) {
+	raw, err := cert.PrivateKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := certcrypto.ParsePEMPrivateKey(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if actual := types.KeyTypeOf(key.(crypto.Signer).Public()); actual != keyType {
+		return nil, fmt.Errorf("key type=%s does not match requested key type=%s", actual, keyType)
+	}
+
+	return key, nil
+}
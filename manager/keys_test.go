@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"crypto"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+func TestCertificateManager_keyOptions(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	opts := mgr.keyOptions(types.KeyOptions{})
+	AssertEquals(t, DefaultKeyType, opts.Type)
+	AssertEquals(t, false, opts.Reuse)
+
+	mgr.KeyType = types.KeyTypeEC384
+	mgr.ReuseKey = true
+	opts = mgr.keyOptions(types.KeyOptions{Type: types.KeyTypeRSA4096})
+	AssertEquals(t, types.KeyTypeRSA4096, opts.Type)
+	AssertEquals(t, true, opts.Reuse)
+
+	opts = mgr.keyOptions(types.KeyOptions{})
+	AssertEquals(t, types.KeyTypeEC384, opts.Type)
+}
+
+func TestCertificateManager_privateKey(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	cert, key := createTestCertificate(t, time.Now().Add(time.Hour), "www.example.com")
+	existing := &types.Certificate{
+		ID:          "cert",
+		Domains:     []string{"www.example.com"},
+		Certificate: base64.StdEncoding.EncodeToString([]byte(cert)),
+		Key:         base64.StdEncoding.EncodeToString([]byte(key)),
+	}
+
+	previous, err := reusableKey(existing, types.KeyTypeEC256)
+	if err != nil {
+		t.Fatalf("Failed to read existing key: %v", err)
+	}
+
+	{
+		// existing key is reused when requested
+		reused, err := mgr.privateKey(types.KeyOptions{Type: types.KeyTypeEC256, Reuse: true}, existing)
+		if err != nil {
+			t.Fatalf("Failed to create private key: %v", err)
+		}
+		if !previous.(interface{ Equal(crypto.PrivateKey) bool }).Equal(reused) {
+			t.Errorf("Expected private key of existing certificate to be reused")
+		}
+	}
+
+	{
+		// new key is generated without reuse
+		generated, err := mgr.privateKey(types.KeyOptions{Type: types.KeyTypeEC256}, existing)
+		if err != nil {
+			t.Fatalf("Failed to create private key: %v", err)
+		}
+		if previous.(interface{ Equal(crypto.PrivateKey) bool }).Equal(generated) {
+			t.Errorf("Expected a new private key to be generated")
+		}
+	}
+
+	{
+		// new key is generated when the key type changed
+		generated, err := mgr.privateKey(types.KeyOptions{Type: types.KeyTypeEC384, Reuse: true}, existing)
+		if err != nil {
+			t.Fatalf("Failed to create private key: %v", err)
+		}
+		AssertEquals(t, types.KeyTypeEC384, types.KeyTypeOf(generated.(crypto.Signer).Public()))
+	}
+
+	{
+		// unknown key types are rejected
+		if _, err := mgr.privateKey(types.KeyOptions{Type: "EC521"}, nil); err == nil {
+			t.Errorf("Expected unknown key type to be rejected")
+		}
+	}
+}
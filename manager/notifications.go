@@ -0,0 +1,307 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+	"github.com/jorenkoyen/conter/version"
+	"github.com/jorenkoyen/go-logger"
+	"github.com/jorenkoyen/go-logger/log"
+)
+
+var (
+	// DefaultNotificationInterval is the minimum time between two notifications for the same event and domains.
+	DefaultNotificationInterval = 24 * time.Hour
+
+	// RenewalFailureThreshold is the amount of failed attempts after which a failing renewal is notified.
+	// A single failure is retried automatically and does not require attention.
+	RenewalFailureThreshold = 3
+
+	// ExpiryAlertCutOff is the duration until the expiry date when a certificate renewed automatically is notified.
+	// By this time the renewal started at ExpiryCutOff should have succeeded.
+	ExpiryAlertCutOff = 14 * 24 * time.Hour
+
+	// SMTPTimeout is the maximum time for sending an email when the context has no deadline.
+	SMTPTimeout = 30 * time.Second
+)
+
+// Notifier delivers a notification to an external system.
+type Notifier interface {
+	// Name will return the name of the notifier used for logging.
+	Name() string
+	// Notify will deliver the notification.
+	Notify(ctx context.Context, notification *types.Notification) error
+}
+
+// WebhookNotifier will send notifications as JSON body in a POST request.
+type WebhookNotifier struct {
+	URL     string
+	Headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhookNotifier creates a new notifier sending to the webhook URL.
+func NewWebhookNotifier(url string, headers map[string]string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:     url,
+		Headers: headers,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (w *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, notification *types.Notification) error {
+	content, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d from webhook", res.StatusCode)
+	}
+
+	return nil
+}
+
+// SMTPNotifier will send notifications as plain text email.
+type SMTPNotifier struct {
+	Address  string // host:port of the SMTP server
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func (s *SMTPNotifier) Name() string {
+	return "smtp"
+}
+
+// Notify will send the email the same way as smtp.SendMail, the connection is bound to the deadline of the context.
+func (s *SMTPNotifier) Notify(ctx context.Context, notification *types.Notification) error {
+	host, _, err := net.SplitHostPort(s.Address)
+	if err != nil {
+		return err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(SMTPTimeout)
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", s.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// abort the conversation once the context is cancelled
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	if err = conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if s.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(s.From); err != nil {
+		return err
+	}
+
+	for _, recipient := range s.To {
+		if err = client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = writer.Write(s.message(notification)); err != nil {
+		return err
+	}
+
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// message will create the email message for the notification.
+func (s *SMTPNotifier) message(notification *types.Notification) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: [conter] %s (%s)\r\n", notification.Event, strings.Join(notification.Domains, ", "))
+	fmt.Fprintf(&b, "Date: %s\r\n", notification.CreatedAt.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\n", notification.Message)
+	fmt.Fprintf(&b, "Event: %s\r\n", notification.Event)
+	fmt.Fprintf(&b, "Domains: %s\r\n", strings.Join(notification.Domains, ", "))
+	if notification.CertificateID != "" {
+		fmt.Fprintf(&b, "Certificate: %s\r\n", notification.CertificateID)
+	}
+	return b.Bytes()
+}
+
+// NotificationManager will deliver notifications to all notifiers.
+// The same event for the same domains is only delivered once per interval.
+type NotificationManager struct {
+	logger    *logger.Logger
+	data      *db.Client
+	mutex     sync.Mutex
+	sending   map[string]struct{} // keys of the notifications currently being delivered
+	notifiers []Notifier
+	Interval  time.Duration
+}
+
+// NewNotificationManager creates a new manager without any notifiers.
+func NewNotificationManager(database *db.Client) *NotificationManager {
+	return &NotificationManager{
+		logger:   log.WithName("notification-mgr"),
+		data:     database,
+		sending:  make(map[string]struct{}),
+		Interval: DefaultNotificationInterval,
+	}
+}
+
+// Register will add the notifier to the notifiers receiving all notifications.
+func (n *NotificationManager) Register(notifier Notifier) {
+	n.notifiers = append(n.notifiers, notifier)
+}
+
+// Notify will deliver the notification to all notifiers unless it was already delivered within the interval.
+// The notification is considered delivered when at least one notifier succeeded.
+func (n *NotificationManager) Notify(ctx context.Context, notification *types.Notification) error {
+	if len(n.notifiers) == 0 {
+		return nil
+	}
+
+	key := notification.Key()
+	if !n.acquire(key, notification) {
+		return nil
+	}
+
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+
+	var errs []error
+	delivered := false
+	for _, notifier := range n.notifiers {
+		if err := notifier.Notify(ctx, notification); err != nil {
+			n.logger.Errorf("Failed to send notification for event=%s using notifier=%s: %v", notification.Event, notifier.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", notifier.Name(), err))
+			continue
+		}
+
+		delivered = true
+	}
+
+	if delivered {
+		n.logger.Infof("Sent notification for event=%s (domains=%v)", notification.Event, notification.Domains)
+	}
+
+	if err := n.release(key, notification.CreatedAt, delivered); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// acquire will mark the notification as being delivered.
+// It will return false if the notification was delivered within the interval or is being delivered right now.
+func (n *NotificationManager) acquire(key string, notification *types.Notification) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if _, ok := n.sending[key]; ok {
+		n.logger.Debugf("Notification for event=%s is already being sent, skipping (domains=%v)", notification.Event, notification.Domains)
+		return false
+	}
+
+	if sent := n.data.GetNotificationSent(key); !sent.IsZero() && time.Since(sent) < n.Interval {
+		n.logger.Debugf("Notification for event=%s already sent at %s, skipping (domains=%v)", notification.Event, sent.Format(time.RFC3339), notification.Domains)
+		return false
+	}
+
+	n.sending[key] = struct{}{}
+	return true
+}
+
+// release will remove the notification from the notifications being delivered, the delivery time is kept when delivered.
+func (n *NotificationManager) release(key string, sent time.Time, delivered bool) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	delete(n.sending, key)
+	if !delivered {
+		return nil
+	}
+
+	return n.data.SetNotificationSent(key, sent)
+}
+
+// Resolve will forget the notifications sent for the events and domains.
+// The next occurrence of the event will be delivered immediately.
+func (n *NotificationManager) Resolve(domains []string, events ...types.NotificationEvent) {
+	for _, event := range events {
+		notification := &types.Notification{Event: event, Domains: domains}
+		if err := n.data.RemoveNotificationSent(notification.Key()); err != nil {
+			n.logger.Warningf("Failed to resolve notification for event=%s: %v", event, err)
+		}
+	}
+}
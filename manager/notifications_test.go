@@ -0,0 +1,200 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+// recordingNotifier keeps all notifications it received.
+type recordingNotifier struct {
+	received []*types.Notification
+}
+
+func (r *recordingNotifier) Name() string {
+	return "recording"
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, notification *types.Notification) error {
+	r.received = append(r.received, notification)
+	return nil
+}
+
+func TestNotificationManager_Notify(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+
+	received := make(chan types.Notification, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertEquals(t, "Bearer token", r.Header.Get("Authorization"))
+
+		var notification types.Notification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		received <- notification
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(webhook.Close)
+
+	mgr := NewNotificationManager(database)
+	mgr.Register(NewWebhookNotifier(webhook.URL, map[string]string{"Authorization": "Bearer token"}))
+	notification := func() *types.Notification {
+		return &types.Notification{Event: types.NotificationExpiring, Domains: []string{"www.example.com"}, Message: "expiring"}
+	}
+
+	{
+		// notification is delivered as JSON
+		if err := mgr.Notify(context.Background(), notification()); err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+
+		AssertEquals(t, 1, len(received))
+		delivered := <-received
+		AssertEquals(t, types.NotificationExpiring, delivered.Event)
+		AssertEquals(t, "www.example.com", delivered.Domains[0])
+	}
+
+	{
+		// same notification is only delivered once per interval
+		if err := mgr.Notify(context.Background(), notification()); err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+		AssertEquals(t, 0, len(received))
+	}
+
+	{
+		// resolved notification is delivered again
+		mgr.Resolve([]string{"www.example.com"}, types.NotificationExpiring)
+		if err := mgr.Notify(context.Background(), notification()); err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+		AssertEquals(t, 1, len(received))
+	}
+
+	{
+		// notification is delivered again after the interval
+		<-received
+		mgr.Interval = 0
+		if err := mgr.Notify(context.Background(), notification()); err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+		AssertEquals(t, 1, len(received))
+	}
+}
+
+func TestNotificationManager_Notify_failed(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(webhook.Close)
+
+	mgr := NewNotificationManager(database)
+	mgr.Register(NewWebhookNotifier(webhook.URL, nil))
+
+	notification := &types.Notification{Event: types.NotificationExpiring, Domains: []string{"www.example.com"}}
+	if err := mgr.Notify(context.Background(), notification); err == nil {
+		t.Errorf("Expected an error when the webhook fails")
+	}
+
+	if !database.GetNotificationSent(notification.Key()).IsZero() {
+		t.Errorf("Expected failed notification not to be marked as sent")
+	}
+}
+
+func TestSMTPNotifier_message(t *testing.T) {
+	notifier := &SMTPNotifier{From: "conter@example.com", To: []string{"ops@example.com", "dev@example.com"}}
+	message := string(notifier.message(&types.Notification{
+		Event:         types.NotificationRenewalFailing,
+		Domains:       []string{"www.example.com", "example.com"},
+		CertificateID: "abc",
+		Message:       "Failed to renew certificate",
+		CreatedAt:     time.Now(),
+	}))
+
+	for _, expected := range []string{
+		"To: ops@example.com, dev@example.com\r\n",
+		"Subject: [conter] certificate.renewal_failing (www.example.com, example.com)\r\n",
+		"\r\n\r\nFailed to renew certificate\r\n",
+		"Certificate: abc\r\n",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected message to contain %q, got: %s", expected, message)
+		}
+	}
+}
+
+func TestSMTPNotifier_deadline(t *testing.T) {
+	// the server accepts the connection but never greets the client
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	notifier := &SMTPNotifier{Address: listener.Addr().String(), From: "conter@example.com", To: []string{"ops@example.com"}}
+	start := time.Now()
+	if err = notifier.Notify(ctx, &types.Notification{Event: types.NotificationExpiring}); err == nil {
+		t.Errorf("Expected notification to fail once the deadline passed")
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected notification to give up at the deadline, took %s", elapsed)
+	}
+
+	_ = (<-accepted).Close()
+}
+
+func TestCertificateManager_notifyFailure(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+
+	recorder := new(recordingNotifier)
+	mgr := NewCertificateManger(database, "", nil, false)
+	mgr.Notifications = NewNotificationManager(database)
+	mgr.Notifications.Register(recorder)
+
+	{
+		// failed issuance is notified immediately
+		mgr.notifyFailure(&types.CertificateOrder{Domains: []string{"new.example.com"}, Attempts: 1, LastError: "boom"})
+		AssertEquals(t, 1, len(recorder.received))
+		AssertEquals(t, types.NotificationIssuanceFailed, recorder.received[0].Event)
+	}
+
+	if _, err := mgr.IssueInternal([]string{"www.example.com"}, types.KeyOptions{}); err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+
+	{
+		// failed renewal is only notified once it keeps failing
+		mgr.notifyFailure(&types.CertificateOrder{Domains: []string{"www.example.com"}, Attempts: 1})
+		AssertEquals(t, 1, len(recorder.received))
+
+		mgr.notifyFailure(&types.CertificateOrder{Domains: []string{"www.example.com"}, Attempts: RenewalFailureThreshold})
+		AssertEquals(t, 2, len(recorder.received))
+		AssertEquals(t, types.NotificationRenewalFailing, recorder.received[1].Event)
+	}
+}
@@ -0,0 +1,293 @@
+package manager
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/google/uuid"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+const (
+	// OrderRetryBackoff is the delay before the first retry of a failed order, it doubles with every failed attempt.
+	OrderRetryBackoff = time.Minute
+	// OrderRateLimitBackoff is the minimum delay before retrying an order that failed because of an ACME rate limit.
+	OrderRateLimitBackoff = time.Hour
+	// OrderMaxBackoff is the maximum delay between two attempts of the same order.
+	OrderMaxBackoff = 24 * time.Hour
+
+	acmeRateLimitedError = "urn:ietf:params:acme:error:rateLimited"
+)
+
+// OrderBackoff will return the delay before the next attempt of an order that failed the given amount of attempts.
+func OrderBackoff(attempts int, rateLimited bool) time.Duration {
+	backoff := OrderMaxBackoff
+	if attempts <= 16 {
+		backoff = min(OrderRetryBackoff<<max(attempts-1, 0), OrderMaxBackoff)
+	}
+
+	if rateLimited {
+		backoff = max(backoff, OrderRateLimitBackoff)
+	}
+
+	return backoff
+}
+
+// IsRateLimited will return true if the error was caused by hitting a rate limit of the ACME server.
+func IsRateLimited(err error) bool {
+	var problem *acme.ProblemDetails
+	if errors.As(err, &problem) {
+		return problem.Type == acmeRateLimitedError || problem.HTTPStatus == 429
+	}
+
+	return false
+}
+
+// GetOrders will return all certificate orders known to the system.
+func (c *CertificateManager) GetOrders() []types.CertificateOrder {
+	return c.data.GetAllCertificateOrders()
+}
+
+// order will return the existing order for the domains or start a new one.
+func (c *CertificateManager) order(domains []string, challenge types.ChallengeType, key types.KeyOptions) *types.CertificateOrder {
+	now := time.Now()
+	order, err := c.data.GetCertificateOrder(types.OrderKey(domains))
+	if err != nil {
+		return &types.CertificateOrder{
+			Domains:       domains,
+			ChallengeType: challenge,
+			KeyType:       key.Type,
+			ReuseKey:      key.Reuse,
+			State:         types.OrderStatePending,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+	}
+
+	if order.State == types.OrderStateValid {
+		// previous order succeeded, start counting again for the renewal
+		order.Attempts = 0
+		order.LastError = ""
+		order.RateLimited = false
+	}
+
+	order.ChallengeType = challenge
+	order.KeyType = key.Type
+	order.ReuseKey = key.Reuse
+	return order
+}
+
+// submit will persist the order as pending and obtain the certificate in the background.
+// It will not submit the order if it is already being processed.
+func (c *CertificateManager) submit(order *types.CertificateOrder) {
+	key := order.Key()
+
+	c.mutex.Lock()
+	if _, ok := c.inflight[key]; ok {
+		c.mutex.Unlock()
+		c.logger.Infof("Order for domains=%s is already being processed, skipping", key)
+		return
+	}
+	c.inflight[key] = struct{}{}
+	c.mutex.Unlock()
+
+	order.State = types.OrderStatePending
+	order.UpdatedAt = time.Now()
+	if err := c.data.SaveCertificateOrder(order); err != nil {
+		c.logger.Errorf("Failed to save order for domains=%s: %v", key, err)
+	}
+
+	go func() {
+		defer func() {
+			c.mutex.Lock()
+			delete(c.inflight, key)
+			c.mutex.Unlock()
+		}()
+
+		c.obtain(order)
+	}()
+}
+
+// obtain will request the certificate for the order and record the outcome.
+// Every issuer able to solve the challenge is tried in order of preference until one succeeds.
+func (c *CertificateManager) obtain(order *types.CertificateOrder) {
+	key := order.Key()
+	req := certificate.ObtainRequest{
+		Domains: order.Domains,
+		Bundle:  true,
+	}
+
+	order.Attempts++
+	order.RateLimited = false
+
+	// the certificate being renewed, the CA is told it is replaced when it supports ARI
+	existing := c.get(order.Domains[0])
+
+	if order.KeyType == "" {
+		// order persisted before key types were configurable
+		order.KeyType = c.keyOptions(types.KeyOptions{}).Type
+	}
+
+	var err error
+	req.PrivateKey, err = c.privateKey(types.KeyOptions{Type: order.KeyType, Reuse: order.ReuseKey}, existing)
+	if err != nil {
+		c.logger.Errorf("Failed to create private key for domains=%s: %v", key, err)
+		order.State = types.OrderStateFailed
+		order.LastError = fmt.Sprintf("failed to create private key: %v", err)
+		order.UpdatedAt = time.Now()
+		order.NextRetry = order.UpdatedAt.Add(OrderBackoff(order.Attempts, false))
+		c.saveOrder(order)
+		c.notifyFailure(order)
+		return
+	}
+
+	var errs []error
+	var resource *certificate.Resource
+	for _, iss := range c.issuersFor(order.ChallengeType) {
+		order.Issuer = iss.name
+		req.ReplacesCertID = c.replaces(existing, iss)
+		c.logger.Infof("Requesting certificate bundle (domains=%s, issuer=%s, key_type=%s, attempt=%d)", key, iss.name, order.KeyType, order.Attempts)
+
+		resource, err = iss.client(order.ChallengeType).Certificate.Obtain(req)
+		if err == nil {
+			break
+		}
+
+		if IsRateLimited(err) {
+			order.RateLimited = true
+		}
+
+		c.logger.Warningf("Failed to obtain certificates from issuer=%s: %v (domains=%s)", iss.name, err, key)
+		errs = append(errs, fmt.Errorf("issuer=%s: %w", iss.name, err))
+	}
+
+	order.UpdatedAt = time.Now()
+	if resource == nil {
+		if len(errs) == 0 {
+			errs = append(errs, fmt.Errorf("challenge type=%s is not configured", order.ChallengeType))
+		}
+
+		order.State = types.OrderStateFailed
+		order.LastError = errors.Join(errs...).Error()
+		order.NextRetry = order.UpdatedAt.Add(OrderBackoff(order.Attempts, order.RateLimited))
+		c.logger.Errorf("Failed to obtain certificates from all issuers (domains=%s, next_retry=%s)", key, order.NextRetry.Format(time.RFC3339))
+		c.saveOrder(order)
+		c.notifyFailure(order)
+		return
+	}
+
+	c.logger.Infof("Successfully obtained certificate bundle (domains=%s, issuer=%s, uri=%s)", key, order.Issuer, resource.CertURL)
+	cert := &types.Certificate{
+		ID:            uuid.NewString(),
+		Certificate:   base64.StdEncoding.EncodeToString(resource.Certificate),
+		Key:           base64.StdEncoding.EncodeToString(resource.PrivateKey),
+		ChallengeType: order.ChallengeType,
+		Domains:       order.Domains,
+		Issuer:        order.Issuer,
+		KeyType:       order.KeyType,
+		ReuseKey:      order.ReuseKey,
+		OnDemand:      order.OnDemand,
+	}
+
+	// persist the certificate for each domain
+	if err := c.data.SetCertificate(cert); err != nil {
+		c.logger.Errorf("Failed to save certificate for: %v", err)
+		order.State = types.OrderStateFailed
+		order.LastError = fmt.Sprintf("failed to save certificate: %v", err)
+		order.NextRetry = order.UpdatedAt.Add(OrderBackoff(order.Attempts, false))
+		c.saveOrder(order)
+		c.notifyFailure(order)
+		return
+	}
+
+	order.State = types.OrderStateValid
+	order.LastError = ""
+	order.CertificateID = cert.ID
+	order.NextRetry = time.Time{}
+	c.saveOrder(order)
+
+	if c.Notifications != nil {
+		c.Notifications.Resolve(order.Domains, types.NotificationIssuanceFailed, types.NotificationRenewalFailing, types.NotificationExpiring)
+	}
+}
+
+// notifyFailure will notify about the failed order.
+// The failed renewal of an existing certificate is only notified once it keeps failing.
+func (c *CertificateManager) notifyFailure(order *types.CertificateOrder) {
+	existing := c.get(order.Domains[0])
+	if existing == nil {
+		c.notify(types.NotificationIssuanceFailed, order.Domains, "", "Failed to obtain certificate after %d attempts: %s", order.Attempts, order.LastError)
+		return
+	}
+
+	if order.Attempts >= RenewalFailureThreshold {
+		c.notify(types.NotificationRenewalFailing, order.Domains, existing.ID, "Failed to renew certificate after %d attempts: %s", order.Attempts, order.LastError)
+	}
+}
+
+// saveOrder will persist the order and log any failure.
+func (c *CertificateManager) saveOrder(order *types.CertificateOrder) {
+	if err := c.data.SaveCertificateOrder(order); err != nil {
+		c.logger.Errorf("Failed to save order for domains=%s: %v", order.Key(), err)
+	}
+}
+
+// RetryOrders will resubmit all failed orders that are due for a retry.
+// Orders left pending by a previous run of the daemon are resubmitted, orders for domains without routes are removed
+// unless they were requested on-demand.
+func (c *CertificateManager) RetryOrders() error {
+	now := time.Now()
+	var errs []error
+	for _, order := range c.GetOrders() {
+		key := order.Key()
+
+		if !order.OnDemand && !c.isRouted(order.Domains) {
+			c.logger.Infof("Order for domains=%s is no longer in use, removing from system", key)
+			if err := c.data.RemoveCertificateOrder(key); err != nil {
+				errs = append(errs, fmt.Errorf("domains=%s: %w", key, err))
+			}
+			continue
+		}
+
+		switch order.State {
+		case types.OrderStateValid:
+			continue
+		case types.OrderStateFailed:
+			if now.Before(order.NextRetry) {
+				continue
+			}
+		case types.OrderStatePending:
+			c.mutex.Lock()
+			_, processing := c.inflight[key]
+			c.mutex.Unlock()
+			if processing {
+				continue
+			}
+		}
+
+		if len(c.issuersFor(order.ChallengeType)) == 0 {
+			errs = append(errs, fmt.Errorf("domains=%s: challenge type=%s is not configured", key, order.ChallengeType))
+			continue
+		}
+
+		c.logger.Infof("Retrying order for domains=%s (state=%s, attempts=%d)", key, order.State, order.Attempts)
+		c.submit(&order)
+	}
+
+	return errors.Join(errs...)
+}
+
+// isRouted will return true if any of the domains is still used by an ingress route, wildcard routes included.
+func (c *CertificateManager) isRouted(domains []string) bool {
+	for _, domain := range domains {
+		if c.data.IsDomainRouted(domain) {
+			return true
+		}
+	}
+
+	return false
+}
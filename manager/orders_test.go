@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+func TestOrderBackoff(t *testing.T) {
+	AssertEquals(t, time.Minute, OrderBackoff(1, false))
+	AssertEquals(t, 2*time.Minute, OrderBackoff(2, false))
+	AssertEquals(t, 16*time.Minute, OrderBackoff(5, false))
+	AssertEquals(t, OrderMaxBackoff, OrderBackoff(20, false))
+	AssertEquals(t, OrderMaxBackoff, OrderBackoff(100, false))
+
+	// rate limits are never retried within the hour
+	AssertEquals(t, OrderRateLimitBackoff, OrderBackoff(1, true))
+	AssertEquals(t, OrderMaxBackoff, OrderBackoff(100, true))
+}
+
+func TestIsRateLimited(t *testing.T) {
+	limited := &acme.ProblemDetails{Type: "urn:ietf:params:acme:error:rateLimited", HTTPStatus: 429}
+	if !IsRateLimited(fmt.Errorf("error: one or more domains had a problem:\n%w", limited)) {
+		t.Errorf("Expected wrapped rate limit problem to be detected")
+	}
+
+	if IsRateLimited(&acme.ProblemDetails{Type: "urn:ietf:params:acme:error:unauthorized", HTTPStatus: 403}) {
+		t.Errorf("Expected unauthorized problem not to be a rate limit")
+	}
+
+	if IsRateLimited(errors.New("connection refused")) {
+		t.Errorf("Expected plain error not to be a rate limit")
+	}
+}
+
+func TestCertificateManager_RetryOrders(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	route := &types.Ingress{Domains: []string{"www.example.com"}, TargetEndpoints: []string{"127.0.0.1:8080"}}
+	if err := database.SaveIngressRoute(route); err != nil {
+		t.Fatalf("Failed to save route: %v", err)
+	}
+
+	routed := &types.CertificateOrder{Domains: []string{"www.example.com"}, State: types.OrderStateFailed, NextRetry: time.Now().Add(time.Hour)}
+	unrouted := &types.CertificateOrder{Domains: []string{"old.example.com"}, State: types.OrderStateFailed}
+	onDemand := &types.CertificateOrder{Domains: []string{"customer.com"}, State: types.OrderStateFailed, NextRetry: time.Now().Add(time.Hour), OnDemand: true}
+	for _, order := range []*types.CertificateOrder{routed, unrouted, onDemand} {
+		if err := database.SaveCertificateOrder(order); err != nil {
+			t.Fatalf("Failed to save order: %v", err)
+		}
+	}
+
+	if err := mgr.RetryOrders(); err != nil {
+		t.Errorf("Expected no errors for orders that are backing off: %v", err)
+	}
+
+	if _, err := database.GetCertificateOrder(unrouted.Key()); !errors.Is(err, db.ErrItemNotFound) {
+		t.Errorf("Expected order without routes to be removed")
+	}
+
+	order, err := database.GetCertificateOrder(routed.Key())
+	if err != nil {
+		t.Fatalf("Expected routed order to be kept: %v", err)
+	}
+	AssertEquals(t, types.OrderStateFailed, order.State)
+
+	// on-demand orders keep their backoff without a route
+	order, err = database.GetCertificateOrder(onDemand.Key())
+	if err != nil {
+		t.Fatalf("Expected on-demand order to be kept: %v", err)
+	}
+	AssertEquals(t, onDemand.NextRetry.Unix(), order.NextRetry.Unix())
+}
+
+func TestCertificateManager_order(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	previous := &types.CertificateOrder{Domains: []string{"b.example.com", "a.example.com"}, State: types.OrderStateValid, Attempts: 3, LastError: "boom"}
+	if err := database.SaveCertificateOrder(previous); err != nil {
+		t.Fatalf("Failed to save order: %v", err)
+	}
+
+	// domain order does not matter and a renewal resets the attempts
+	order := mgr.order([]string{"a.example.com", "b.example.com"}, types.ChallengeTypeHTTP, types.KeyOptions{Type: types.KeyTypeEC256})
+	AssertEquals(t, 0, order.Attempts)
+	AssertEquals(t, "", order.LastError)
+	AssertEquals(t, "a.example.com,b.example.com", order.Key())
+	AssertEquals(t, types.KeyTypeEC256, order.KeyType)
+}
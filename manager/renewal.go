@@ -0,0 +1,130 @@
+package manager
+
+import (
+	"crypto/x509"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+var (
+	// RenewalInfoInterval is the time between two ARI checks when the CA does not suggest one.
+	RenewalInfoInterval = 6 * time.Hour
+
+	// MaxRenewalInfoInterval limits the time between two ARI checks, so a changed window is never missed for long.
+	MaxRenewalInfoInterval = 24 * time.Hour
+)
+
+// shouldRenew will return true if the certificate is due for renewal.
+// The renewal window suggested by the CA is used when available, otherwise the certificate is renewed within the ExpiryCutOff.
+func (c *CertificateManager) shouldRenew(cert types.Certificate, info *x509.Certificate, now time.Time) bool {
+	if renewal := c.renewalInfo(cert, info, now); renewal != nil {
+		return !now.Before(renewal.RenewAt)
+	}
+
+	return now.Add(ExpiryCutOff).After(info.NotAfter)
+}
+
+// renewalInfo will return the renewal window of the certificate suggested by the CA.
+// The ARI endpoint of the issuer is only queried when the stored window is due for a check.
+// It will return nil if the certificate was not issued through ACME or the CA does not support ARI.
+func (c *CertificateManager) renewalInfo(cert types.Certificate, info *x509.Certificate, now time.Time) *types.RenewalInfo {
+	if !isAcmeChallenge(cert.ChallengeType) {
+		return nil
+	}
+
+	stored, _ := c.data.GetRenewalInfo(cert.ID)
+	if stored != nil && now.Before(stored.NextCheck) {
+		return stored
+	}
+
+	iss := c.issuer(cert.Issuer)
+	if iss == nil {
+		return stored
+	}
+
+	client := iss.anyClient(cert.ChallengeType)
+	if client == nil {
+		return stored
+	}
+
+	resp, err := client.Certificate.GetRenewalInfo(certificate.RenewalInfoRequest{Cert: info})
+	if errors.Is(err, api.ErrNoARI) {
+		c.logger.Tracef("Issuer=%s does not support ARI, using expiry cutoff for certificate with id=%s", iss.name, cert.ID)
+		return nil
+	} else if err != nil {
+		c.logger.Warningf("Failed to retrieve renewal information for certificate with id=%s: %v", cert.ID, err)
+		return stored
+	}
+
+	renewal := nextRenewalInfo(cert.ID, stored, resp, now)
+	if stored == nil || !renewal.RenewAt.Equal(stored.RenewAt) {
+		c.logger.Infof("Certificate with id=%s will be renewed at %s (window=%s - %s)", cert.ID, renewal.RenewAt.Format(time.RFC3339), renewal.WindowStart.Format(time.RFC3339), renewal.WindowEnd.Format(time.RFC3339))
+		if renewal.ExplanationURL != "" {
+			c.logger.Warningf("CA provided an explanation for the renewal window of certificate with id=%s: %s", cert.ID, renewal.ExplanationURL)
+		}
+	}
+
+	if err = c.data.SetRenewalInfo(renewal); err != nil {
+		c.logger.Errorf("Failed to save renewal information for certificate with id=%s: %v", cert.ID, err)
+	}
+
+	return renewal
+}
+
+// nextRenewalInfo will create the renewal information from the ARI response.
+// The renewal time is selected at random within the window, it is kept as long as the window does not change.
+func nextRenewalInfo(id string, stored *types.RenewalInfo, resp *certificate.RenewalInfoResponse, now time.Time) *types.RenewalInfo {
+	renewal := &types.RenewalInfo{
+		CertificateID:  id,
+		WindowStart:    resp.SuggestedWindow.Start,
+		WindowEnd:      resp.SuggestedWindow.End,
+		ExplanationURL: resp.ExplanationURL,
+		CheckedAt:      now,
+	}
+
+	if stored != nil && stored.WindowStart.Equal(renewal.WindowStart) && stored.WindowEnd.Equal(renewal.WindowEnd) {
+		renewal.RenewAt = stored.RenewAt
+	} else {
+		renewal.RenewAt = renewal.WindowStart
+		if window := renewal.WindowEnd.Sub(renewal.WindowStart); window > 0 {
+			renewal.RenewAt = renewal.RenewAt.Add(time.Duration(rand.Int63n(int64(window))))
+		}
+	}
+
+	interval := resp.RetryAfter
+	if interval <= 0 {
+		interval = RenewalInfoInterval
+	}
+
+	renewal.NextCheck = now.Add(min(interval, MaxRenewalInfoInterval))
+	return renewal
+}
+
+// replaces will return the ARI certificate ID of the certificate being renewed by the issuer.
+// It will return an empty string if the CA did not provide renewal information for the certificate.
+func (c *CertificateManager) replaces(existing *types.Certificate, iss *issuer) string {
+	if existing == nil || c.issuer(existing.Issuer) != iss {
+		return ""
+	}
+
+	if _, err := c.data.GetRenewalInfo(existing.ID); err != nil {
+		return ""
+	}
+
+	info, err := existing.Parse()
+	if err != nil {
+		return ""
+	}
+
+	id, err := certificate.MakeARICertID(info)
+	if err != nil {
+		return ""
+	}
+
+	return id
+}
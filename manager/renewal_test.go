@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+func TestNextRenewalInfo(t *testing.T) {
+	now := time.Now()
+	start, end := now.Add(24*time.Hour), now.Add(48*time.Hour)
+	resp := &certificate.RenewalInfoResponse{
+		RenewalInfoResponse: acme.RenewalInfoResponse{SuggestedWindow: acme.Window{Start: start, End: end}},
+	}
+
+	renewal := nextRenewalInfo("cert", nil, resp, now)
+	if renewal.RenewAt.Before(start) || !renewal.RenewAt.Before(end) {
+		t.Errorf("Expected renewal time within window, got %s", renewal.RenewAt)
+	}
+	AssertEquals(t, now.Add(RenewalInfoInterval), renewal.NextCheck)
+
+	{
+		// renewal time is kept while the window does not change
+		stored := *renewal
+		stored.RenewAt = start.Add(time.Hour)
+		next := nextRenewalInfo("cert", &stored, resp, now)
+		AssertEquals(t, stored.RenewAt, next.RenewAt)
+	}
+
+	{
+		// renewal time is moved when the window changes
+		stored := *renewal
+		stored.WindowStart = now.Add(-time.Hour)
+		stored.RenewAt = now.Add(-time.Hour)
+		next := nextRenewalInfo("cert", &stored, resp, now)
+		if next.RenewAt.Before(start) {
+			t.Errorf("Expected renewal time within new window, got %s", next.RenewAt)
+		}
+	}
+
+	{
+		// suggested retry is limited to the maximum interval
+		retry := *resp
+		retry.RetryAfter = 7 * 24 * time.Hour
+		next := nextRenewalInfo("cert", nil, &retry, now)
+		AssertEquals(t, now.Add(MaxRenewalInfoInterval), next.NextCheck)
+	}
+}
+
+func TestCertificateManager_shouldRenew(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+	mgr := NewCertificateManger(database, "", nil, false)
+
+	now := time.Now()
+	cert, key := createTestCertificate(t, now.Add(60*24*time.Hour), "www.example.com")
+	stored := types.Certificate{ID: "cert", Domains: []string{"www.example.com"}, Certificate: base64.StdEncoding.EncodeToString([]byte(cert)), Key: base64.StdEncoding.EncodeToString([]byte(key)), ChallengeType: types.ChallengeTypeHTTP}
+	info, err := stored.Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	{
+		// expiry cutoff is used without renewal information
+		if mgr.shouldRenew(stored, info, now) {
+			t.Errorf("Expected certificate not to be renewed before the expiry cutoff")
+		}
+		if !mgr.shouldRenew(stored, info, now.Add(31*24*time.Hour)) {
+			t.Errorf("Expected certificate to be renewed within the expiry cutoff")
+		}
+	}
+
+	renewal := &types.RenewalInfo{
+		CertificateID: stored.ID,
+		WindowStart:   now.Add(-time.Hour),
+		WindowEnd:     now.Add(time.Hour),
+		RenewAt:       now.Add(-time.Minute),
+		CheckedAt:     now,
+		NextCheck:     now.Add(RenewalInfoInterval),
+	}
+	if err = database.SetRenewalInfo(renewal); err != nil {
+		t.Fatalf("Failed to save renewal information: %v", err)
+	}
+
+	{
+		// suggested window is used when available
+		if !mgr.shouldRenew(stored, info, now) {
+			t.Errorf("Expected certificate to be renewed within the suggested window")
+		}
+		if mgr.shouldRenew(stored, info, now.Add(-2*time.Minute)) {
+			t.Errorf("Expected certificate not to be renewed before the selected time")
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package manager
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+// RouteTable is an immutable snapshot of all ingress routes optimized for matching incoming requests.
+type RouteTable struct {
+	domains map[string][]*types.Ingress // sorted by path prefix length (longest first)
+	routes  []types.Ingress
+}
+
+// NewRouteTable will create the route table from the routes with the key being the route key.
+func NewRouteTable(routes map[string]types.Ingress) *RouteTable {
+	table := &RouteTable{
+		domains: make(map[string][]*types.Ingress),
+		routes:  make([]types.Ingress, 0, len(routes)),
+	}
+
+	for key, route := range routes {
+		// the domain is everything before the path of the route key
+		domain := key
+		if idx := strings.Index(key, "/"); idx != -1 {
+			domain = key[:idx]
+		}
+
+		table.domains[domain] = append(table.domains[domain], &route)
+		table.routes = append(table.routes, route)
+	}
+
+	for _, candidates := range table.domains {
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return len(candidates[a].PathPrefix()) > len(candidates[b].PathPrefix())
+		})
+	}
+
+	return table
+}
+
+// Match will return the route with the longest path prefix matching the request path for the domain.
+// When the domain itself has no matching route the wildcard covering the domain will be tried.
+// It will return nil if no route matches.
+func (t *RouteTable) Match(domain string, path string) *types.Ingress {
+	if route := t.match(domain, path); route != nil {
+		return route
+	}
+
+	if wildcard := types.WildcardOf(domain); wildcard != "" {
+		return t.match(wildcard, path)
+	}
+
+	return nil
+}
+
+// match will return the route with the longest path prefix for the exact domain.
+func (t *RouteTable) match(domain string, path string) *types.Ingress {
+	for _, route := range t.domains[domain] {
+		if types.PathHasPrefix(path, route.Path) {
+			return route
+		}
+	}
+
+	return nil
+}
+
+// HasDomain will return true if a route exists for the domain or the wildcard covering the domain.
+func (t *RouteTable) HasDomain(domain string) bool {
+	if len(t.domains[domain]) > 0 {
+		return true
+	}
+
+	wildcard := types.WildcardOf(domain)
+	return wildcard != "" && len(t.domains[wildcard]) > 0
+}
+
+// Routes will return all routes within the table.
+func (t *RouteTable) Routes() []types.Ingress {
+	return t.routes
+}
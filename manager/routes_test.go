@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+func TestRouteTable_Match(t *testing.T) {
+	web := types.Ingress{Domains: []string{"example.com"}, Path: "/", TargetService: "web"}
+	api := types.Ingress{Domains: []string{"example.com"}, Path: "/api", TargetService: "api"}
+	legacy := types.Ingress{Domains: []string{"legacy.com"}, TargetService: "legacy"}
+	customers := types.Ingress{Domains: []string{"*.customers.com"}, Path: "/", TargetService: "customers"}
+	portal := types.Ingress{Domains: []string{"portal.customers.com"}, Path: "/", TargetService: "portal"}
+
+	table := NewRouteTable(map[string]types.Ingress{
+		"example.com/":          web,
+		"example.com/api":       api,
+		"legacy.com":            legacy,
+		"*.customers.com/":      customers,
+		"portal.customers.com/": portal,
+	})
+
+	cases := []struct {
+		domain   string
+		path     string
+		expected string
+	}{
+		{"example.com", "/", "web"},
+		{"example.com", "/apis", "web"},
+		{"example.com", "/api", "api"},
+		{"example.com", "/api/users", "api"},
+		{"legacy.com", "/anything", "legacy"},
+		{"acme.customers.com", "/", "customers"},
+		{"portal.customers.com", "/", "portal"},
+	}
+
+	for _, c := range cases {
+		match := table.Match(c.domain, c.path)
+		if match == nil {
+			t.Errorf("Expected a route for domain=%s, path=%s", c.domain, c.path)
+			continue
+		}
+
+		if match.TargetService != c.expected {
+			t.Errorf("Expected service=%s for domain=%s, path=%s, got service=%s", c.expected, c.domain, c.path, match.TargetService)
+		}
+	}
+
+	if table.Match("unknown.com", "/") != nil {
+		t.Errorf("Expected no route for an unknown domain")
+	}
+
+	if table.Match("a.b.customers.com", "/") != nil {
+		t.Errorf("Expected wildcard to only match a single label")
+	}
+}
+
+// createBenchmarkIngressManager will create an ingress manager backed by a temporary database with routes for several domains.
+func createBenchmarkIngressManager(b *testing.B) *IngressManager {
+	b.Helper()
+	database := db.NewClient(b.TempDir())
+	b.Cleanup(func() { _ = database.Close() })
+
+	for i := 0; i < 50; i++ {
+		for _, path := range []string{"/", "/api", "/static"} {
+			route := &types.Ingress{
+				Domains:         []string{fmt.Sprintf("www%d.example.com", i)},
+				Path:            path,
+				TargetEndpoints: []string{"127.0.0.1:30000"},
+				TargetService:   "web",
+				TargetProject:   "benchmark",
+			}
+
+			if err := database.SaveIngressRoute(route); err != nil {
+				b.Fatalf("Failed to save route: %v", err)
+			}
+		}
+	}
+
+	mgr := NewIngressManager()
+	mgr.Database = database
+	database.OnRoutesChanged(mgr.Rebuild)
+	return mgr
+}
+
+func BenchmarkIngressManager_Match(b *testing.B) {
+	mgr := createBenchmarkIngressManager(b)
+
+	b.Run("database", func(b *testing.B) {
+		// the lookup as it was done before the in-memory route table
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var match *types.Ingress
+			for _, route := range mgr.Database.GetIngressRoutesByDomain("www25.example.com") {
+				if types.PathHasPrefix("/api/users", route.Path) && (match == nil || len(route.PathPrefix()) > len(match.PathPrefix())) {
+					match = &route
+				}
+			}
+
+			if match == nil || match.PathPrefix() != "/api" {
+				b.Fatalf("Unexpected match: %v", match)
+			}
+		}
+	})
+
+	b.Run("table", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			match, err := mgr.Match("www25.example.com", "/api/users")
+			if err != nil || match.PathPrefix() != "/api" {
+				b.Fatalf("Unexpected match: %v (err=%v)", match, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/jorenkoyen/go-logger"
+	"github.com/jorenkoyen/go-logger/log"
+)
+
+const (
+	JobCertificateBatch = "batch_certificates"
+	JobChallengeCleanup = "cleanup_challenges"
+	JobOrderRetry       = "retry_orders"
+	JobOCSPRefresh      = "refresh_ocsp"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+)
+
+// JobFunc is the unit of work executed by the scheduler.
+type JobFunc func(ctx context.Context) error
+
+// JobOptions configure when a job is executed by the scheduler.
+type JobOptions struct {
+	// Interval is the time between two consecutive runs.
+	Interval time.Duration
+	// Jitter is the maximum random delay added to every interval.
+	Jitter time.Duration
+	// RunOnStart indicates if the job should run immediately when the scheduler is started.
+	RunOnStart bool
+}
+
+// JobStatus contains the execution state of a scheduled job.
+type JobStatus struct {
+	Name         string
+	Options      JobOptions
+	Running      bool
+	Runs         int
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    string
+	NextRun      time.Time
+}
+
+// Result will return the textual representation of the last run of the job.
+func (s JobStatus) Result() string {
+	switch {
+	case s.Running:
+		return "running"
+	case s.Runs == 0:
+		return "pending"
+	case s.LastError != "":
+		return "failed"
+	default:
+		return "success"
+	}
+}
+
+type job struct {
+	fn     JobFunc
+	status JobStatus
+}
+
+// Scheduler will periodically execute the registered maintenance jobs of the daemon.
+type Scheduler struct {
+	logger *logger.Logger
+	mutex  sync.Mutex
+	jobs   map[string]*job
+	order  []string
+	ctx    context.Context
+}
+
+// NewScheduler creates a new scheduler without any jobs.
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		logger: log.WithName("scheduler"),
+		jobs:   make(map[string]*job),
+		ctx:    context.Background(),
+	}
+}
+
+// Register will add the job to the scheduler, jobs should be registered before the scheduler is started.
+func (s *Scheduler) Register(name string, opts JobOptions, fn JobFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.jobs[name]; !exists {
+		s.order = append(s.order, name)
+	}
+
+	s.jobs[name] = &job{fn: fn, status: JobStatus{Name: name, Options: opts}}
+}
+
+// Start will execute the registered jobs on their interval until the context is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mutex.Lock()
+	s.ctx = ctx
+	delays := make(map[string]time.Duration, len(s.order))
+	for _, name := range s.order {
+		opts := s.jobs[name].status.Options
+		delays[name] = nextDelay(opts)
+		if opts.RunOnStart {
+			delays[name] = 0
+		}
+
+		s.jobs[name].status.NextRun = time.Now().Add(delays[name])
+	}
+	s.mutex.Unlock()
+
+	s.logger.Debugf("Starting scheduler with %d jobs", len(delays))
+
+	var wg sync.WaitGroup
+	for name, delay := range delays {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, name, delay)
+		}()
+	}
+
+	wg.Wait()
+	s.logger.Trace("Stopped scheduler")
+}
+
+// loop will run the job every time it is due until the context is cancelled.
+func (s *Scheduler) loop(ctx context.Context, name string, delay time.Duration) {
+	s.mutex.Lock()
+	opts := s.jobs[name].status.Options
+	s.mutex.Unlock()
+
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			if err := s.Run(name); err != nil && !errors.Is(err, ErrJobRunning) {
+				s.logger.Errorf("Scheduled job=%s failed: %v", name, err)
+			}
+		}
+
+		delay = nextDelay(opts)
+		s.mutex.Lock()
+		s.jobs[name].status.NextRun = time.Now().Add(delay)
+		s.mutex.Unlock()
+	}
+}
+
+// Run will execute the job immediately and wait for it to complete.
+func (s *Scheduler) Run(name string) error {
+	s.mutex.Lock()
+	j, ok := s.jobs[name]
+	if !ok {
+		s.mutex.Unlock()
+		return ErrJobNotFound
+	}
+	if j.status.Running {
+		s.mutex.Unlock()
+		return ErrJobRunning
+	}
+
+	j.status.Running = true
+	ctx := s.ctx
+	s.mutex.Unlock()
+
+	s.logger.Debugf("Running job=%s", name)
+	start := time.Now()
+	err := s.execute(ctx, j.fn)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastRun = start
+	j.status.LastDuration = time.Since(start)
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+	}
+
+	return err
+}
+
+// execute will call the job and convert a panic into an error so a single job can not stop the daemon.
+func (s *Scheduler) execute(ctx context.Context, fn JobFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return fn(ctx)
+}
+
+// Jobs will return the status of all registered jobs in order of registration.
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	output := make([]JobStatus, 0, len(s.order))
+	for _, name := range s.order {
+		output = append(output, s.jobs[name].status)
+	}
+
+	return output
+}
+
+// nextDelay will return the delay until the next run including a random jitter.
+func nextDelay(opts JobOptions) time.Duration {
+	if opts.Jitter <= 0 {
+		return opts.Interval
+	}
+
+	return opts.Interval + rand.N(opts.Jitter)
+}
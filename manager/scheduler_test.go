@@ -0,0 +1,78 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Run(t *testing.T) {
+	scheduler := NewScheduler()
+	scheduler.Register("ok", JobOptions{Interval: time.Hour}, func(ctx context.Context) error { return nil })
+	scheduler.Register("failing", JobOptions{Interval: time.Hour}, func(ctx context.Context) error { return errors.New("boom") })
+	scheduler.Register("panic", JobOptions{Interval: time.Hour}, func(ctx context.Context) error { panic("oops") })
+
+	if err := scheduler.Run("unknown"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected job not found error, got: %v", err)
+	}
+
+	if err := scheduler.Run("ok"); err != nil {
+		t.Errorf("Expected job to succeed: %v", err)
+	}
+	if err := scheduler.Run("failing"); err == nil {
+		t.Errorf("Expected job to fail")
+	}
+	if err := scheduler.Run("panic"); err == nil {
+		t.Errorf("Expected panic to be returned as error")
+	}
+
+	jobs := scheduler.Jobs()
+	if len(jobs) != 3 {
+		t.Fatalf("Expected 3 jobs, got %d", len(jobs))
+	}
+
+	AssertEquals(t, "ok", jobs[0].Name)
+	AssertEquals(t, "success", jobs[0].Result())
+	AssertEquals(t, 1, jobs[0].Runs)
+	AssertEquals(t, "failed", jobs[1].Result())
+	AssertEquals(t, "boom", jobs[1].LastError)
+	AssertEquals(t, "failed", jobs[2].Result())
+}
+
+func TestScheduler_Start(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ran := make(chan struct{}, 1)
+	scheduler := NewScheduler()
+	scheduler.Register("on-start", JobOptions{Interval: time.Hour, RunOnStart: true}, func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	})
+	scheduler.Register("later", JobOptions{Interval: time.Hour, Jitter: time.Minute}, func(ctx context.Context) error {
+		t.Errorf("Job should not run before its interval")
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		scheduler.Start(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected job to run on start")
+	}
+
+	later := scheduler.Jobs()[1]
+	if later.NextRun.Before(time.Now().Add(time.Hour - time.Second)) {
+		t.Errorf("Expected next run to be planned after the interval, got: %s", later.NextRun)
+	}
+	AssertEquals(t, "pending", later.Result())
+
+	cancel()
+	<-done
+}
@@ -2,12 +2,16 @@ package types
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"github.com/go-acme/lego/v4/registration"
+	"time"
 )
 
 // AcmeRegistration contains all the information in relation to a complete ACME registration.
@@ -34,8 +38,48 @@ func (u *AcmeRegistration) IsValid() bool {
 
 // AcmeChallenge represents an ACME challenge.
 type AcmeChallenge struct {
-	Token string
-	Auth  string
+	Token     string
+	Auth      string
+	CreatedAt time.Time
+}
+
+// KeyType is the algorithm of the private key of a certificate.
+type KeyType string
+
+const (
+	KeyTypeEC256   KeyType = "EC256"
+	KeyTypeEC384   KeyType = "EC384"
+	KeyTypeRSA2048 KeyType = "RSA2048"
+	KeyTypeRSA4096 KeyType = "RSA4096"
+)
+
+// KeyTypeOf will return the key type of the public key.
+// It will return an empty key type if the algorithm is not known.
+func KeyTypeOf(key crypto.PublicKey) KeyType {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return KeyTypeEC256
+		case elliptic.P384():
+			return KeyTypeEC384
+		}
+	case *rsa.PublicKey:
+		switch k.N.BitLen() {
+		case 2048:
+			return KeyTypeRSA2048
+		case 4096:
+			return KeyTypeRSA4096
+		}
+	}
+
+	return ""
+}
+
+// KeyOptions are the settings for the private key of a requested certificate.
+type KeyOptions struct {
+	Type  KeyType // empty for the key type configured for the daemon
+	Reuse bool    // reuse the private key of the existing certificate when renewing
 }
 
 type Certificate struct {
@@ -44,6 +88,32 @@ type Certificate struct {
 	Certificate   string        `json:"certificate"`
 	ChallengeType ChallengeType `json:"challenge_type"`
 	Domains       []string      `json:"domains"`
+	Issuer        string        `json:"issuer,omitempty"` // name of the ACME issuer, empty for the preferred issuer
+	KeyType       KeyType       `json:"key_type,omitempty"`
+	ReuseKey      bool          `json:"reuse_key,omitempty"`
+	OnDemand      bool          `json:"on_demand,omitempty"` // issued at the first TLS handshake, the domains do not require a route
+}
+
+// IsRetained will return true if the certificate is kept while none of its domains has a route.
+// Imported certificates are only removed on request, on-demand certificates serve domains without a route.
+func (c *Certificate) IsRetained() bool {
+	return c.ChallengeType == ChallengeTypeManual || c.OnDemand
+}
+
+// KeyOptions will return the key settings the certificate was requested with, they are used again on renewal.
+func (c *Certificate) KeyOptions() KeyOptions {
+	return KeyOptions{Type: c.KeyType, Reuse: c.ReuseKey}
+}
+
+// RenewalInfo is the renewal window suggested by the CA through ACME Renewal Information (ARI).
+type RenewalInfo struct {
+	CertificateID  string    `json:"certificate_id"`
+	WindowStart    time.Time `json:"window_start"`
+	WindowEnd      time.Time `json:"window_end"`
+	RenewAt        time.Time `json:"renew_at"` // selected within the window, kept until the window changes
+	ExplanationURL string    `json:"explanation_url,omitempty"`
+	CheckedAt      time.Time `json:"checked_at"`
+	NextCheck      time.Time `json:"next_check"`
 }
 
 // CertificateBytes will return the bytes of the certificate.
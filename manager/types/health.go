@@ -0,0 +1,142 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultHealthCheckInterval           = 10
+	DefaultHealthCheckTimeout            = 2
+	DefaultHealthCheckHealthyThreshold   = 2
+	DefaultHealthCheckUnhealthyThreshold = 3
+
+	DefaultContainerHealthCheckInterval = 30
+	DefaultContainerHealthCheckTimeout  = 5
+	DefaultContainerHealthCheckRetries  = 3
+)
+
+// HealthCheck describes how the proxy verifies that an endpoint of a service is able to handle requests.
+type HealthCheck struct {
+	Path               string `json:"path"`
+	Interval           int    `json:"interval"` // seconds
+	Timeout            int    `json:"timeout"`  // seconds
+	HealthyThreshold   int    `json:"healthy_threshold"`
+	UnhealthyThreshold int    `json:"unhealthy_threshold"`
+}
+
+// IntervalDuration will return the duration between two consecutive checks.
+func (h *HealthCheck) IntervalDuration() time.Duration {
+	return secondsOrDefault(h.Interval, DefaultHealthCheckInterval)
+}
+
+// TimeoutDuration will return the maximum duration of a single check.
+func (h *HealthCheck) TimeoutDuration() time.Duration {
+	return secondsOrDefault(h.Timeout, DefaultHealthCheckTimeout)
+}
+
+// HealthyAfter will return the amount of consecutive successful checks required to mark an endpoint as healthy.
+func (h *HealthCheck) HealthyAfter() int {
+	if h.HealthyThreshold <= 0 {
+		return DefaultHealthCheckHealthyThreshold
+	}
+	return h.HealthyThreshold
+}
+
+// UnhealthyAfter will return the amount of consecutive failed checks required to mark an endpoint as unhealthy.
+func (h *HealthCheck) UnhealthyAfter() int {
+	if h.UnhealthyThreshold <= 0 {
+		return DefaultHealthCheckUnhealthyThreshold
+	}
+	return h.UnhealthyThreshold
+}
+
+// Probe will execute a single HTTP check against the endpoint.
+// Any response with a status code below 400 is considered successful.
+func (h *HealthCheck) Probe(ctx context.Context, client *http.Client, endpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, h.TimeoutDuration())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+endpoint+NormalizePath(h.Path), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ContainerHealthCheck describes how docker verifies that the containers of a service are healthy.
+// Either the command is executed inside the container or the path is requested on the container port.
+type ContainerHealthCheck struct {
+	Command     []string `json:"command,omitempty"` // exit code 0 is healthy
+	Path        string   `json:"path,omitempty"`    // any status code below 400 is healthy
+	Interval    int      `json:"interval"`          // seconds
+	Timeout     int      `json:"timeout"`           // seconds
+	Retries     int      `json:"retries"`
+	StartPeriod int      `json:"start_period"` // seconds, failures are not counted while the container starts
+}
+
+// IntervalDuration will return the duration between two consecutive checks.
+func (h *ContainerHealthCheck) IntervalDuration() time.Duration {
+	return secondsOrDefault(h.Interval, DefaultContainerHealthCheckInterval)
+}
+
+// TimeoutDuration will return the maximum duration of a single check.
+func (h *ContainerHealthCheck) TimeoutDuration() time.Duration {
+	return secondsOrDefault(h.Timeout, DefaultContainerHealthCheckTimeout)
+}
+
+// RetryCount will return the amount of consecutive failed checks required to mark a container as unhealthy.
+func (h *ContainerHealthCheck) RetryCount() int {
+	if h.Retries <= 0 {
+		return DefaultContainerHealthCheckRetries
+	}
+	return h.Retries
+}
+
+// StartPeriodDuration will return the time a container is given to start before failures are counted.
+func (h *ContainerHealthCheck) StartPeriodDuration() time.Duration {
+	return time.Duration(max(h.StartPeriod, 0)) * time.Second
+}
+
+// Test will return the docker test for the health check of a container listening on the port.
+// The HTTP check requires either 'curl' or 'wget' to be available inside the container.
+func (h *ContainerHealthCheck) Test(port int) []string {
+	if len(h.Command) > 0 {
+		return append([]string{"CMD"}, h.Command...)
+	}
+
+	url := fmt.Sprintf("http://localhost:%d%s", port, NormalizePath(h.Path))
+	return []string{"CMD-SHELL", fmt.Sprintf("curl -fsS -o /dev/null %[1]s || wget -q -O /dev/null %[1]s || exit 1", url)}
+}
+
+// String will return a short description of the health check.
+func (h *ContainerHealthCheck) String() string {
+	check := "http " + NormalizePath(h.Path)
+	if len(h.Command) > 0 {
+		check = "cmd " + strings.Join(h.Command, " ")
+	}
+
+	return fmt.Sprintf("%s (interval=%s, timeout=%s, retries=%d, start_period=%s)", check, h.IntervalDuration(), h.TimeoutDuration(), h.RetryCount(), h.StartPeriodDuration())
+}
+
+// secondsOrDefault will convert the seconds to a duration, using the default value when not specified.
+func secondsOrDefault(seconds int, defaultValue int) time.Duration {
+	if seconds <= 0 {
+		seconds = defaultValue
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -12,23 +12,134 @@ const (
 	ChallengeTypeDNS  ChallengeType = "DNS-01"
 	ChallengeTypeTLS  ChallengeType = "TLS-ALPN-01"
 	ChallengeTypeNone ChallengeType = "NONE"
+	// ChallengeTypeManual is used for certificates that are provided by the user instead of an ACME server.
+	ChallengeTypeManual ChallengeType = "MANUAL"
+	// ChallengeTypeInternal is used for certificates issued by the internal certificate authority of the daemon.
+	ChallengeTypeInternal ChallengeType = "INTERNAL"
+)
+
+type LoadBalancer string
+
+const (
+	LoadBalancerRoundRobin       LoadBalancer = "round_robin"
+	LoadBalancerLeastConnections LoadBalancer = "least_connections"
 )
 
 type Ingress struct {
 	Domains       []string `json:"domains"`
+	Path          string   `json:"path"`
+	StripPrefix   bool     `json:"strip_prefix"`
 	ContainerPort int      `json:"container_port"`
 
-	TargetEndpoint string `json:"target_endpoint"`
-	TargetService  string `json:"target_service"`
-	TargetProject  string `json:"target_project"`
+	TargetEndpoints []string     `json:"target_endpoints"`
+	TargetService   string       `json:"target_service"`
+	TargetProject   string       `json:"target_project"`
+	LoadBalancer    LoadBalancer `json:"load_balancer"`
+	HealthCheck     *HealthCheck `json:"health_check,omitempty"`
+
+	// Deprecated: TargetEndpoint is only read for routes persisted before replicas were supported, use TargetEndpoints.
+	TargetEndpoint string `json:"target_endpoint,omitempty"`
 
 	ChallengeType ChallengeType `json:"challenge_type"`
+	KeyType       KeyType       `json:"key_type,omitempty"`
+	ReuseKey      bool          `json:"reuse_key,omitempty"`
 }
 
 func (i *Ingress) String() string {
-	return fmt.Sprintf("Ingress [ domains=%s, project=%s, service=%s ]",
+	return fmt.Sprintf("Ingress [ domains=%s, path=%s, project=%s, service=%s ]",
 		strings.Join(i.Domains, ","),
+		i.PathPrefix(),
 		i.TargetProject,
 		i.TargetService,
 	)
 }
+
+// KeyOptions will return the settings for the private key of the certificates requested for the ingress.
+func (i *Ingress) KeyOptions() KeyOptions {
+	return KeyOptions{Type: i.KeyType, Reuse: i.ReuseKey}
+}
+
+// Endpoints will return all endpoints the traffic for the ingress can be routed to.
+func (i *Ingress) Endpoints() []string {
+	if len(i.TargetEndpoints) == 0 && i.TargetEndpoint != "" {
+		return []string{i.TargetEndpoint}
+	}
+
+	return i.TargetEndpoints
+}
+
+// PathPrefix will return the normalized path prefix the ingress is listening on.
+func (i *Ingress) PathPrefix() string {
+	return NormalizePath(i.Path)
+}
+
+// RouteKeys will return the unique route keys for each domain linked to the ingress.
+func (i *Ingress) RouteKeys() []string {
+	keys := make([]string, len(i.Domains))
+	for idx, domain := range i.Domains {
+		keys[idx] = RouteKey(domain, i.Path)
+	}
+	return keys
+}
+
+// RouteKey will return the unique key for a route consisting of the domain and path prefix.
+func RouteKey(domain string, path string) string {
+	return domain + NormalizePath(path)
+}
+
+// NormalizePath will make sure the path prefix always starts with a slash and never ends with one (except for the root).
+func NormalizePath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+		if path == "" {
+			return "/"
+		}
+	}
+
+	return path
+}
+
+// PathHasPrefix will return true if the request path falls under the path prefix.
+// Matching is done on path segments, the prefix '/api' matches '/api' and '/api/users' but not '/apis'.
+func PathHasPrefix(path string, prefix string) bool {
+	prefix = NormalizePath(prefix)
+	if prefix == "/" {
+		return true
+	}
+
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+// IsWildcard will return true if the domain is a wildcard domain (e.g. '*.example.com').
+func IsWildcard(domain string) bool {
+	return strings.HasPrefix(domain, "*.")
+}
+
+// WildcardOf will return the wildcard domain that covers the domain (e.g. 'www.example.com' -> '*.example.com').
+// It will return an empty string if the domain can not be covered by a wildcard.
+func WildcardOf(domain string) string {
+	idx := strings.Index(domain, ".")
+	if idx <= 0 || IsWildcard(domain) || !strings.Contains(domain[idx+1:], ".") {
+		return ""
+	}
+
+	return "*" + domain[idx:]
+}
+
+// DomainsOverlap will return true if both domains can receive traffic for the same host.
+// A wildcard only covers a single label, '*.example.com' overlaps with 'www.example.com' but not with 'example.com'.
+func DomainsOverlap(a string, b string) bool {
+	if a == b {
+		return true
+	}
+
+	return WildcardOf(a) == b || WildcardOf(b) == a
+}
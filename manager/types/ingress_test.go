@@ -0,0 +1,77 @@
+package types
+
+import "testing"
+
+func TestNormalizePath(t *testing.T) {
+	cases := map[string]string{
+		"":          "/",
+		"/":         "/",
+		"//":        "/",
+		"api":       "/api",
+		"/api/":     "/api",
+		"/api/v1//": "/api/v1",
+	}
+
+	for input, expected := range cases {
+		if actual := NormalizePath(input); actual != expected {
+			t.Errorf("NormalizePath(%q) expected=%s, actual=%s", input, expected, actual)
+		}
+	}
+}
+
+func TestPathHasPrefix(t *testing.T) {
+	if !PathHasPrefix("/anything", "/") {
+		t.Errorf("Root prefix should match every path")
+	}
+	if !PathHasPrefix("/api", "/api") {
+		t.Errorf("Exact path should match the prefix")
+	}
+	if !PathHasPrefix("/api/users", "/api/") {
+		t.Errorf("Sub path should match the prefix")
+	}
+	if PathHasPrefix("/apis", "/api") {
+		t.Errorf("Prefix should only match on complete path segments")
+	}
+	if PathHasPrefix("/", "/api") {
+		t.Errorf("Root path should not match a longer prefix")
+	}
+}
+
+func TestIngress_RouteKeys(t *testing.T) {
+	ingress := Ingress{Domains: []string{"example.com", "www.example.com"}, Path: "api/"}
+	keys := ingress.RouteKeys()
+	if len(keys) != 2 || keys[0] != "example.com/api" || keys[1] != "www.example.com/api" {
+		t.Errorf("Unexpected route keys: %v", keys)
+	}
+}
+
+func TestWildcardOf(t *testing.T) {
+	cases := map[string]string{
+		"www.example.com":         "*.example.com",
+		"a.customers.example.com": "*.customers.example.com",
+		"example.com":             "",
+		"*.example.com":           "",
+		"localhost":               "",
+	}
+
+	for input, expected := range cases {
+		if actual := WildcardOf(input); actual != expected {
+			t.Errorf("WildcardOf(%q) expected=%q, actual=%q", input, expected, actual)
+		}
+	}
+}
+
+func TestDomainsOverlap(t *testing.T) {
+	if !DomainsOverlap("*.example.com", "*.example.com") {
+		t.Errorf("Identical wildcards should overlap")
+	}
+	if !DomainsOverlap("*.example.com", "www.example.com") {
+		t.Errorf("Wildcard should overlap with a covered domain")
+	}
+	if DomainsOverlap("*.example.com", "example.com") {
+		t.Errorf("Wildcard should not overlap with the apex domain")
+	}
+	if DomainsOverlap("*.example.com", "*.customers.example.com") {
+		t.Errorf("Wildcards on different levels should not overlap")
+	}
+}
@@ -0,0 +1,30 @@
+package types
+
+import (
+	"time"
+)
+
+type NotificationEvent string
+
+const (
+	// NotificationIssuanceFailed is sent when a new certificate could not be obtained.
+	NotificationIssuanceFailed NotificationEvent = "certificate.issuance_failed"
+	// NotificationRenewalFailing is sent when the renewal of an existing certificate keeps failing.
+	NotificationRenewalFailing NotificationEvent = "certificate.renewal_failing"
+	// NotificationExpiring is sent when a certificate in use is about to expire.
+	NotificationExpiring NotificationEvent = "certificate.expiring"
+)
+
+// Notification is an alert about a certificate that requires attention.
+type Notification struct {
+	Event         NotificationEvent `json:"event"`
+	Domains       []string          `json:"domains"`
+	CertificateID string            `json:"certificate_id,omitempty"`
+	Message       string            `json:"message"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// Key will return the key used for deduplicating the notification, it is unique for the event and domains.
+func (n *Notification) Key() string {
+	return string(n.Event) + ":" + OrderKey(n.Domains)
+}
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+type OrderState string
+
+const (
+	OrderStatePending OrderState = "pending"
+	OrderStateValid   OrderState = "valid"
+	OrderStateFailed  OrderState = "failed"
+)
+
+// CertificateOrder keeps track of a certificate request towards the ACME server.
+type CertificateOrder struct {
+	Domains       []string      `json:"domains"`
+	ChallengeType ChallengeType `json:"challenge_type"`
+	State         OrderState    `json:"state"`
+	Attempts      int           `json:"attempts"`
+	LastError     string        `json:"last_error,omitempty"`
+	RateLimited   bool          `json:"rate_limited,omitempty"`
+	CertificateID string        `json:"certificate_id,omitempty"`
+	Issuer        string        `json:"issuer,omitempty"` // last issuer that was attempted
+	KeyType       KeyType       `json:"key_type,omitempty"`
+	ReuseKey      bool          `json:"reuse_key,omitempty"`
+	OnDemand      bool          `json:"on_demand,omitempty"` // requested at the first TLS handshake, kept without a route
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	NextRetry     time.Time     `json:"next_retry,omitempty"`
+}
+
+// Key will return the unique key of the order based on the requested domains.
+func (o *CertificateOrder) Key() string {
+	return OrderKey(o.Domains)
+}
+
+// OrderKey will return the order key for the domains, the order of the domains does not matter.
+func OrderKey(domains []string) string {
+	sorted := slices.Clone(domains)
+	slices.Sort(sorted)
+	return strings.Join(sorted, ",")
+}
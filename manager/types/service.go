@@ -13,11 +13,14 @@ type Service struct {
 	Hash           string            `json:"hash"`
 	ContainerName  string            `json:"container_name"`
 	ContainerImage string            `json:"container_image"`
+	Replicas       int               `json:"replicas"`
 	Source         Source            `json:"source"`
 	Environment    map[string]string `json:"environment"`
 	Quota          Quota             `json:"quota"`
 	Ingress        Ingress           `json:"ingress"`
 	Volumes        []Volume          `json:"volumes"`
+
+	HealthCheck *ContainerHealthCheck `json:"healthcheck,omitempty"`
 }
 
 type Source struct {
@@ -40,6 +43,28 @@ func (s *Service) IsExposed() bool {
 	return len(s.Ingress.Domains) > 0
 }
 
+// ReplicaCount will return the amount of containers that should be running for the service.
+func (s *Service) ReplicaCount() int {
+	if s.Replicas < 1 {
+		return 1
+	}
+	return s.Replicas
+}
+
+// ContainerNames will return the container name of each replica of the service.
+// The first replica always uses the plain container name of the service.
+func (s *Service) ContainerNames() []string {
+	names := make([]string, s.ReplicaCount())
+	for i := range names {
+		if i == 0 {
+			names[i] = s.ContainerName
+		} else {
+			names[i] = fmt.Sprintf("%s.%d", s.ContainerName, i)
+		}
+	}
+	return names
+}
+
 // CalculateHash will calculate the configuration hash for the specified service.
 // This hash will be used to compare versions of the service.
 func CalculateHash(s *Service) string {
@@ -78,6 +103,13 @@ func CalculateHash(s *Service) string {
 		}
 	}
 
+	// include 'healthcheck'
+	if s.HealthCheck != nil {
+		if err := encoder.Encode(s.HealthCheck); err != nil {
+			log.Panicf("Failed to hash health check: %v", err)
+		}
+	}
+
 	h := md5.New()
 	h.Write(buf.Bytes())
 	return fmt.Sprintf("%x", h.Sum(nil)) // hex string
@@ -111,6 +111,22 @@ func TestService_CalculateConfigurationHash(t *testing.T) {
 			t.Errorf("Hash should differ when volumes are defined (hash=%s)", actual)
 		}
 	}
+
+	{
+		// with health check
+		compare := new(Service)
+		compare.Name = base.Name
+		compare.Source.Type = base.Source.Type
+		compare.Source.URI = base.Source.URI
+		compare.Environment = base.Environment
+		compare.HealthCheck = &ContainerHealthCheck{Path: "/health"}
+
+		actual := CalculateHash(base)
+		calculated := CalculateHash(compare)
+		if actual == calculated {
+			t.Errorf("Hash should differ when a health check is defined (hash=%s)", actual)
+		}
+	}
 }
 
 func BenchmarkService_CalculateConfigurationHash(b *testing.B) {
@@ -134,3 +150,16 @@ func BenchmarkService_CalculateConfigurationHash(b *testing.B) {
 		}
 	})
 }
+
+func TestService_ContainerNames(t *testing.T) {
+	service := Service{ContainerName: "project_web"}
+	if names := service.ContainerNames(); len(names) != 1 || names[0] != "project_web" {
+		t.Errorf("Expected a single container without replicas, got %v", names)
+	}
+
+	service.Replicas = 3
+	names := service.ContainerNames()
+	if len(names) != 3 || names[0] != "project_web" || names[1] != "project_web.1" || names[2] != "project_web.2" {
+		t.Errorf("Unexpected container names for replicas: %v", names)
+	}
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+// Balancer decides which endpoint will handle the next request for a route.
+type Balancer interface {
+	// Pick will select one of the endpoints, the endpoints are never empty.
+	Pick(endpoints []string) string
+}
+
+// NewBalancer will create the balancer for the specified strategy.
+// The round-robin strategy is used when no strategy is specified.
+func NewBalancer(strategy types.LoadBalancer, connections *ConnectionTracker) Balancer {
+	switch strategy {
+	case types.LoadBalancerLeastConnections:
+		return &LeastConnectionsBalancer{connections: connections}
+	default:
+		return new(RoundRobinBalancer)
+	}
+}
+
+// RoundRobinBalancer will distribute the requests evenly across all endpoints.
+type RoundRobinBalancer struct {
+	counter atomic.Uint64
+}
+
+func (b *RoundRobinBalancer) Pick(endpoints []string) string {
+	next := b.counter.Add(1) - 1
+	return endpoints[next%uint64(len(endpoints))]
+}
+
+// LeastConnectionsBalancer will send the request to the endpoint with the least active connections.
+type LeastConnectionsBalancer struct {
+	connections *ConnectionTracker
+}
+
+func (b *LeastConnectionsBalancer) Pick(endpoints []string) string {
+	selected := endpoints[0]
+	lowest := b.connections.Active(selected)
+	for _, endpoint := range endpoints[1:] {
+		if active := b.connections.Active(endpoint); active < lowest {
+			selected = endpoint
+			lowest = active
+		}
+	}
+
+	return selected
+}
+
+// ConnectionTracker keeps track of the active connections for each endpoint.
+type ConnectionTracker struct {
+	counters sync.Map // endpoint -> *atomic.Int64
+}
+
+// counter will return the connection counter for the endpoint.
+func (t *ConnectionTracker) counter(endpoint string) *atomic.Int64 {
+	if existing, ok := t.counters.Load(endpoint); ok {
+		return existing.(*atomic.Int64)
+	}
+
+	created, _ := t.counters.LoadOrStore(endpoint, new(atomic.Int64))
+	return created.(*atomic.Int64)
+}
+
+// Acquire will mark a new active connection for the endpoint.
+func (t *ConnectionTracker) Acquire(endpoint string) {
+	t.counter(endpoint).Add(1)
+}
+
+// Release will mark an active connection for the endpoint as completed.
+func (t *ConnectionTracker) Release(endpoint string) {
+	t.counter(endpoint).Add(-1)
+}
+
+// Active will return the amount of active connections for the endpoint.
+func (t *ConnectionTracker) Active(endpoint string) int64 {
+	return t.counter(endpoint).Load()
+}
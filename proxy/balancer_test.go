@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+func TestRoundRobinBalancer_Pick(t *testing.T) {
+	balancer := NewBalancer(types.LoadBalancerRoundRobin, new(ConnectionTracker))
+	endpoints := []string{"127.0.0.1:30000", "127.0.0.1:30001", "127.0.0.1:30002"}
+
+	for i := 0; i < 6; i++ {
+		AssertEquals(t, endpoints[i%len(endpoints)], balancer.Pick(endpoints))
+	}
+}
+
+func TestLeastConnectionsBalancer_Pick(t *testing.T) {
+	connections := new(ConnectionTracker)
+	balancer := NewBalancer(types.LoadBalancerLeastConnections, connections)
+	endpoints := []string{"127.0.0.1:30000", "127.0.0.1:30001"}
+
+	connections.Acquire(endpoints[0])
+	AssertEquals(t, endpoints[1], balancer.Pick(endpoints))
+
+	connections.Acquire(endpoints[1])
+	connections.Acquire(endpoints[1])
+	AssertEquals(t, endpoints[0], balancer.Pick(endpoints))
+
+	connections.Release(endpoints[1])
+	connections.Release(endpoints[1])
+	AssertEquals(t, endpoints[1], balancer.Pick(endpoints))
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"sync"
+	"time"
+)
+
+// MaxSelfSignedCertificates is the maximum amount of self-signed fallback certificates kept in memory.
+// It prevents unknown server names from growing the cache without bounds.
+const MaxSelfSignedCertificates = 1024
+
+// CertificateCache keeps the parsed TLS certificates for each domain in memory.
+// Entries are kept until they expire or the cache is purged.
+type CertificateCache struct {
+	mutex      sync.RWMutex
+	entries    map[string]*cachedCertificate
+	selfSigned int
+}
+
+type cachedCertificate struct {
+	certificate *tls.Certificate
+	selfSigned  bool
+	expiry      time.Time
+}
+
+// NewCertificateCache will create an empty certificate cache.
+func NewCertificateCache() *CertificateCache {
+	return &CertificateCache{entries: make(map[string]*cachedCertificate)}
+}
+
+// Get will return the cached certificate for the domain, expired certificates are never returned.
+func (c *CertificateCache) Get(domain string) (*tls.Certificate, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entry, ok := c.entries[domain]
+	if !ok || time.Now().After(entry.expiry) {
+		return nil, false
+	}
+
+	return entry.certificate, true
+}
+
+// IsSelfSigned will return true if the cached certificate for the domain is a self-signed fallback certificate.
+func (c *CertificateCache) IsSelfSigned(domain string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entry, ok := c.entries[domain]
+	return ok && entry.selfSigned
+}
+
+// Set will cache the certificate for the domain.
+// Self-signed certificates are not cached once the limit of self-signed certificates has been reached.
+func (c *CertificateCache) Set(domain string, certificate *tls.Certificate, selfSigned bool) {
+	if certificate.Leaf == nil {
+		return // unable to determine expiry
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if existing, ok := c.entries[domain]; ok && existing.selfSigned {
+		c.selfSigned--
+	}
+
+	if selfSigned {
+		if c.selfSigned >= MaxSelfSignedCertificates {
+			delete(c.entries, domain)
+			return
+		}
+		c.selfSigned++
+	}
+
+	c.entries[domain] = &cachedCertificate{
+		certificate: certificate,
+		selfSigned:  selfSigned,
+		expiry:      certificate.Leaf.NotAfter,
+	}
+}
+
+// Purge will remove all certificates from the cache.
+func (c *CertificateCache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = make(map[string]*cachedCertificate)
+	c.selfSigned = 0
+}
+
+// Len will return the amount of cached certificates.
+func (c *CertificateCache) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.entries)
+}
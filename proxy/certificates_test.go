@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCertificateCache(t *testing.T) {
+	cache := NewCertificateCache()
+	valid := &tls.Certificate{Leaf: &x509.Certificate{NotAfter: time.Now().Add(time.Hour)}}
+	expired := &tls.Certificate{Leaf: &x509.Certificate{NotAfter: time.Now().Add(-time.Hour)}}
+
+	{
+		// cached certificate is returned
+		cache.Set("www.example.com", valid, false)
+		cert, ok := cache.Get("www.example.com")
+		AssertEquals(t, true, ok)
+		if cert != valid {
+			t.Errorf("Expected cached certificate to be returned")
+		}
+	}
+
+	{
+		// self-signed fallback certificates are recognized
+		AssertEquals(t, false, cache.IsSelfSigned("www.example.com"))
+		cache.Set("fallback.example.com", valid, true)
+		AssertEquals(t, true, cache.IsSelfSigned("fallback.example.com"))
+		AssertEquals(t, false, cache.IsSelfSigned("unknown.example.com"))
+	}
+
+	{
+		// expired certificate is never returned
+		cache.Set("old.example.com", expired, false)
+		_, ok := cache.Get("old.example.com")
+		AssertEquals(t, false, ok)
+	}
+
+	{
+		// certificate without leaf is not cached
+		cache.Set("unknown.example.com", &tls.Certificate{}, false)
+		_, ok := cache.Get("unknown.example.com")
+		AssertEquals(t, false, ok)
+	}
+
+	{
+		// purge removes all certificates
+		cache.Purge()
+		AssertEquals(t, 0, cache.Len())
+	}
+
+	{
+		// self-signed certificates are limited
+		for i := 0; i < MaxSelfSignedCertificates+10; i++ {
+			cache.Set(fmt.Sprintf("%d.example.com", i), valid, true)
+		}
+		AssertEquals(t, MaxSelfSignedCertificates, cache.Len())
+
+		// replacing a self-signed certificate frees its slot
+		cache.Set("0.example.com", valid, false)
+		cache.Set("new.example.com", valid, true)
+		_, ok := cache.Get("new.example.com")
+		AssertEquals(t, true, ok)
+
+		// certificates from the store are always cached
+		cache.Set("www.example.com", valid, false)
+		_, ok = cache.Get("www.example.com")
+		AssertEquals(t, true, ok)
+	}
+}
@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jorenkoyen/conter/manager"
+	"github.com/jorenkoyen/conter/manager/types"
+	"github.com/jorenkoyen/go-logger"
+	"github.com/jorenkoyen/go-logger/log"
+)
+
+const (
+	HealthStatusUnknown   = "unknown"
+	HealthStatusHealthy   = "healthy"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+// EndpointHealth contains the result of the active health checks for a single endpoint.
+type EndpointHealth struct {
+	Endpoint  string
+	Healthy   bool
+	Checked   bool
+	LastCheck time.Time
+	LastError string
+
+	successes int
+	failures  int
+	nextCheck time.Time
+	running   bool
+}
+
+// Status will return the textual representation of the endpoint health.
+func (e EndpointHealth) Status() string {
+	if !e.Checked {
+		return HealthStatusUnknown
+	}
+
+	if e.Healthy {
+		return HealthStatusHealthy
+	}
+
+	return HealthStatusUnhealthy
+}
+
+// HealthChecker will actively probe all endpoints of routes that have a health check configured.
+type HealthChecker struct {
+	logger         *logger.Logger
+	client         *http.Client
+	mutex          sync.Mutex
+	states         map[string]*EndpointHealth
+	unhealthy      sync.Map // endpoints marked as unhealthy, read without locking when proxying requests
+	IngressManager *manager.IngressManager
+}
+
+// NewHealthChecker creates a new prober for verifying the health of the upstream endpoints.
+func NewHealthChecker() *HealthChecker {
+	return &HealthChecker{
+		logger: log.WithName("health-checker"),
+		client: &http.Client{
+			// never follow redirects, a redirect is already considered healthy
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		states: make(map[string]*EndpointHealth),
+	}
+}
+
+// Start will periodically check all endpoints until the context is cancelled.
+func (h *HealthChecker) Start(ctx context.Context) {
+	h.logger.Debug("Starting active health checks for upstream endpoints")
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.logger.Trace("Stopping active health checks")
+			return
+		case <-ticker.C:
+			h.schedule(ctx, time.Now())
+		}
+	}
+}
+
+// schedule will start a probe for every endpoint that is due for a check.
+func (h *HealthChecker) schedule(ctx context.Context, now time.Time) {
+	checks := make(map[string]*types.HealthCheck)
+	for _, route := range h.IngressManager.GetAllRoutes() {
+		if route.HealthCheck == nil {
+			continue
+		}
+
+		for _, endpoint := range route.Endpoints() {
+			checks[endpoint] = route.HealthCheck
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// forget endpoints that are no longer routed to
+	for endpoint := range h.states {
+		if _, ok := checks[endpoint]; !ok {
+			delete(h.states, endpoint)
+			h.unhealthy.Delete(endpoint)
+		}
+	}
+
+	for endpoint, check := range checks {
+		state, ok := h.states[endpoint]
+		if !ok {
+			// endpoints are considered healthy until proven otherwise
+			state = &EndpointHealth{Endpoint: endpoint, Healthy: true}
+			h.states[endpoint] = state
+		}
+
+		if state.running || now.Before(state.nextCheck) {
+			continue
+		}
+
+		state.running = true
+		state.nextCheck = now.Add(check.IntervalDuration())
+		go h.check(ctx, endpoint, check)
+	}
+}
+
+// check will probe the endpoint once and update its health state.
+func (h *HealthChecker) check(ctx context.Context, endpoint string, check *types.HealthCheck) {
+	err := check.Probe(ctx, h.client, endpoint)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	state, ok := h.states[endpoint]
+	if !ok {
+		state = &EndpointHealth{Endpoint: endpoint, Healthy: true}
+		h.states[endpoint] = state
+	}
+
+	state.running = false
+	state.Checked = true
+	state.LastCheck = time.Now()
+
+	if err != nil {
+		state.LastError = err.Error()
+		state.successes = 0
+		state.failures++
+		if state.Healthy && state.failures >= check.UnhealthyAfter() {
+			h.logger.Warningf("Endpoint=%s is unhealthy after %d failed checks: %v", endpoint, state.failures, err)
+			state.Healthy = false
+			h.unhealthy.Store(endpoint, struct{}{})
+		}
+	} else {
+		state.LastError = ""
+		state.failures = 0
+		state.successes++
+		if !state.Healthy && state.successes >= check.HealthyAfter() {
+			h.logger.Infof("Endpoint=%s is healthy again after %d successful checks", endpoint, state.successes)
+			state.Healthy = true
+			h.unhealthy.Delete(endpoint)
+		}
+	}
+}
+
+// IsHealthy will return false if the endpoint has been marked as unhealthy.
+// Endpoints without a health check are always considered healthy.
+func (h *HealthChecker) IsHealthy(endpoint string) bool {
+	_, unhealthy := h.unhealthy.Load(endpoint)
+	return !unhealthy
+}
+
+// Filter will return only the healthy endpoints.
+func (h *HealthChecker) Filter(endpoints []string) []string {
+	healthy := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if h.IsHealthy(endpoint) {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	return healthy
+}
+
+// Get will return a copy of the health state for the endpoint.
+// The status will be unknown when the endpoint is not actively checked.
+func (h *HealthChecker) Get(endpoint string) EndpointHealth {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	state, ok := h.states[endpoint]
+	if !ok {
+		return EndpointHealth{Endpoint: endpoint, Healthy: true}
+	}
+
+	return *state
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+func TestHealthChecker_check(t *testing.T) {
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() || r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	endpoint := strings.TrimPrefix(upstream.URL, "http://")
+	check := &types.HealthCheck{Path: "/health", HealthyThreshold: 2, UnhealthyThreshold: 2}
+	checker := NewHealthChecker()
+	ctx := context.Background()
+
+	AssertEquals(t, HealthStatusUnknown, checker.Get(endpoint).Status())
+
+	checker.check(ctx, endpoint, check)
+	AssertEquals(t, HealthStatusHealthy, checker.Get(endpoint).Status())
+
+	// a single failure is not enough to mark the endpoint unhealthy
+	failing.Store(true)
+	checker.check(ctx, endpoint, check)
+	AssertEquals(t, true, checker.IsHealthy(endpoint))
+
+	checker.check(ctx, endpoint, check)
+	AssertEquals(t, false, checker.IsHealthy(endpoint))
+	AssertEquals(t, 0, len(checker.Filter([]string{endpoint})))
+
+	// recovers after the healthy threshold
+	failing.Store(false)
+	checker.check(ctx, endpoint, check)
+	AssertEquals(t, false, checker.IsHealthy(endpoint))
+
+	checker.check(ctx, endpoint, check)
+	AssertEquals(t, true, checker.IsHealthy(endpoint))
+	AssertEquals(t, "", checker.Get(endpoint).LastError)
+}
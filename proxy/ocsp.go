@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/jorenkoyen/conter/manager"
+	"github.com/jorenkoyen/go-logger"
+	"github.com/jorenkoyen/go-logger/log"
+	"golang.org/x/crypto/ocsp"
+)
+
+// MaxOCSPResponseSize is the maximum size of an OCSP response accepted from a responder.
+const MaxOCSPResponseSize = 1024 * 1024
+
+// ocspStaple is the latest OCSP response known for a certificate.
+type ocspStaple struct {
+	raw        []byte
+	thisUpdate time.Time
+	nextUpdate time.Time
+}
+
+// due will return true if the response should be refreshed.
+// Responses are refreshed halfway through their validity period.
+func (s *ocspStaple) due(now time.Time) bool {
+	if s.nextUpdate.IsZero() {
+		return true
+	}
+
+	return now.After(s.thisUpdate.Add(s.nextUpdate.Sub(s.thisUpdate) / 2))
+}
+
+// OCSPStapler will fetch and cache the OCSP responses of all stored certificates.
+type OCSPStapler struct {
+	logger             *logger.Logger
+	client             *http.Client
+	mutex              sync.RWMutex
+	staples            map[string]*ocspStaple // serial number -> response
+	refreshing         sync.Mutex
+	CertificateManager *manager.CertificateManager
+}
+
+// NewOCSPStapler creates a new stapler for fetching OCSP responses.
+func NewOCSPStapler() *OCSPStapler {
+	return &OCSPStapler{
+		logger:  log.WithName("ocsp-stapler"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		staples: make(map[string]*ocspStaple),
+	}
+}
+
+// Staple will return the certificate with the cached OCSP response attached.
+// The certificate is returned as is if no valid response is available.
+func (o *OCSPStapler) Staple(certificate *tls.Certificate) *tls.Certificate {
+	if certificate.Leaf == nil {
+		return certificate
+	}
+
+	o.mutex.RLock()
+	staple, ok := o.staples[certificate.Leaf.SerialNumber.String()]
+	o.mutex.RUnlock()
+
+	if !ok || (!staple.nextUpdate.IsZero() && time.Now().After(staple.nextUpdate)) {
+		return certificate
+	}
+
+	// copy the certificate as it is shared between handshakes
+	stapled := *certificate
+	stapled.OCSPStaple = staple.raw
+	return &stapled
+}
+
+// Refresh will fetch the OCSP response for all certificates without a response or due for a refresh.
+// Responses of certificates no longer stored are removed. It does nothing if a refresh is already running.
+func (o *OCSPStapler) Refresh() error {
+	if !o.refreshing.TryLock() {
+		return nil
+	}
+	defer o.refreshing.Unlock()
+
+	now := time.Now()
+	stored := make(map[string]bool)
+	var errs []error
+	for _, cert := range o.CertificateManager.GetAll() {
+		bundle, err := cert.CertificateBytes()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("certificate=%s: %w", cert.ID, err))
+			continue
+		}
+
+		certificates, err := certcrypto.ParsePEMBundle(bundle)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("certificate=%s: %w", cert.ID, err))
+			continue
+		}
+
+		leaf := certificates[0]
+		if len(leaf.OCSPServer) == 0 || len(certificates) < 2 {
+			continue // OCSP is not supported for the certificate
+		}
+
+		serial := leaf.SerialNumber.String()
+		stored[serial] = true
+
+		o.mutex.RLock()
+		staple, ok := o.staples[serial]
+		o.mutex.RUnlock()
+		if ok && !staple.due(now) {
+			continue
+		}
+
+		staple, err = o.fetch(leaf, certificates[1])
+		if err != nil {
+			o.logger.Warningf("Failed to fetch OCSP response for certificate with id=%s: %v", cert.ID, err)
+			errs = append(errs, fmt.Errorf("certificate=%s: %w", cert.ID, err))
+			continue
+		}
+
+		o.logger.Debugf("Refreshed OCSP response for certificate with id=%s (next_update=%s)", cert.ID, staple.nextUpdate.Format(time.RFC3339))
+		o.mutex.Lock()
+		o.staples[serial] = staple
+		o.mutex.Unlock()
+	}
+
+	o.mutex.Lock()
+	for serial := range o.staples {
+		if !stored[serial] {
+			delete(o.staples, serial)
+		}
+	}
+	o.mutex.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// fetch will request the OCSP response for the certificate from the responder of the issuer.
+func (o *OCSPStapler) fetch(leaf *x509.Certificate, issuer *x509.Certificate) (*ocspStaple, error) {
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+
+	res, err := o.client.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from OCSP responder", res.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(res.Body, MaxOCSPResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OCSP response: %w", err)
+	}
+
+	if response.Status != ocsp.Good {
+		return nil, fmt.Errorf("certificate status is not good (status=%d)", response.Status)
+	}
+
+	return &ocspStaple{raw: raw, thisUpdate: response.ThisUpdate, nextUpdate: response.NextUpdate}, nil
+}
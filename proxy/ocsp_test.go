@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/jorenkoyen/conter/manager"
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+	"golang.org/x/crypto/ocsp"
+)
+
+// createOCSPResponder will start a local OCSP responder answering with the given status for every request.
+// It returns the stored certificate signed by the responder's issuer and the amount of requests received.
+func createOCSPResponder(t *testing.T, status int) (*types.Certificate, *atomic.Int32) {
+	t.Helper()
+	issuerKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	issuerTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	issuerDER, err := x509.CreateCertificate(rand.Reader, issuerTemplate, issuerTemplate, &issuerKey.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("Failed to create issuer: %v", err)
+	}
+	issuer, _ := x509.ParseCertificate(issuerDER)
+
+	requests := new(atomic.Int32)
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		response, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+			Status:       status,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now().Add(-time.Minute),
+		}, issuerKey)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(response)
+	}))
+	t.Cleanup(responder.Close)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"www.example.com"},
+		OCSPServer:   []string{responder.URL},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	chain := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issuerDER})...)
+	return &types.Certificate{
+		ID:            "ocsp",
+		Certificate:   base64.StdEncoding.EncodeToString(chain),
+		Key:           base64.StdEncoding.EncodeToString(certcrypto.PEMEncode(key)),
+		ChallengeType: types.ChallengeTypeHTTP,
+		Domains:       []string{"www.example.com"},
+	}, requests
+}
+
+func TestOCSPStapler_Refresh(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+
+	stapler := NewOCSPStapler()
+	stapler.CertificateManager = manager.NewCertificateManger(database, "", nil, false)
+
+	s := NewServer()
+	s.CertificateManager = stapler.CertificateManager
+	s.OCSPStapler = stapler
+	hello := &tls.ClientHelloInfo{ServerName: "www.example.com"}
+
+	cert, requests := createOCSPResponder(t, ocsp.Good)
+	if err := database.SetCertificate(cert); err != nil {
+		t.Fatalf("Failed to save certificate: %v", err)
+	}
+
+	{
+		// no staple before the response has been fetched
+		served, err := s.getCertificate(hello)
+		if err != nil {
+			t.Fatalf("Expected certificate: %v", err)
+		}
+		AssertEquals(t, 0, len(served.OCSPStaple))
+	}
+
+	{
+		// response is fetched and attached to the certificate
+		if err := stapler.Refresh(); err != nil {
+			t.Fatalf("Failed to refresh OCSP responses: %v", err)
+		}
+
+		served, err := s.getCertificate(hello)
+		if err != nil {
+			t.Fatalf("Expected certificate: %v", err)
+		}
+
+		response, err := ocsp.ParseResponse(served.OCSPStaple, nil)
+		if err != nil {
+			t.Fatalf("Expected a valid OCSP staple: %v", err)
+		}
+		AssertEquals(t, ocsp.Good, response.Status)
+
+		cached, _ := s.certificates.Get("www.example.com")
+		if len(cached.OCSPStaple) != 0 {
+			t.Errorf("Expected the cached certificate not to be modified")
+		}
+	}
+
+	{
+		// response is not fetched again until it is due
+		if err := stapler.Refresh(); err != nil {
+			t.Fatalf("Failed to refresh OCSP responses: %v", err)
+		}
+		AssertEquals(t, int32(1), requests.Load())
+	}
+
+	{
+		// response is removed together with the certificate
+		if err := database.RemoveCertificate(cert); err != nil {
+			t.Fatalf("Failed to remove certificate: %v", err)
+		}
+
+		if err := stapler.Refresh(); err != nil {
+			t.Fatalf("Failed to refresh OCSP responses: %v", err)
+		}
+		AssertEquals(t, 0, len(stapler.staples))
+	}
+}
+
+func TestOCSPStapler_Refresh_revoked(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+
+	stapler := NewOCSPStapler()
+	stapler.CertificateManager = manager.NewCertificateManger(database, "", nil, false)
+
+	cert, _ := createOCSPResponder(t, ocsp.Revoked)
+	if err := database.SetCertificate(cert); err != nil {
+		t.Fatalf("Failed to save certificate: %v", err)
+	}
+
+	if err := stapler.Refresh(); err == nil {
+		t.Errorf("Expected an error for a revoked certificate")
+	}
+	AssertEquals(t, 0, len(stapler.staples))
+}
@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jorenkoyen/conter/manager"
+	"github.com/jorenkoyen/conter/manager/types"
+	"github.com/jorenkoyen/go-logger"
+	"github.com/jorenkoyen/go-logger/log"
+)
+
+// DefaultOnDemandInterval is the minimum time between two on-demand issuance attempts for the same domain.
+const DefaultOnDemandInterval = 10 * time.Minute
+
+// MaxOnDemandDomains is the maximum amount of domains for which the last attempt is remembered.
+// Once reached no new domains are attempted until the interval of earlier attempts has passed.
+const MaxOnDemandDomains = 1024
+
+// OnDemandIssuer will request certificates for server names without a certificate at their first TLS handshake.
+// A certificate is only requested when the domain is permitted by the allow-list or the ask endpoint.
+type OnDemandIssuer struct {
+	logger   *logger.Logger
+	client   *http.Client
+	mutex    sync.Mutex
+	attempts map[string]time.Time // domain -> last attempt
+
+	// AllowedDomains are the domains permitted without asking, a wildcard ('*.example.com') permits all subdomains.
+	AllowedDomains []string
+	// AskURL is called with the 'domain' query parameter, a 200 response permits the domain.
+	AskURL string
+	// ChallengeType is the ACME challenge used for issuing the certificates.
+	ChallengeType types.ChallengeType
+	// Interval is the minimum time between two attempts for the same domain.
+	Interval time.Duration
+	// RequireRoute only requests certificates for domains that have a route, either their own or a wildcard route.
+	RequireRoute bool
+
+	IngressManager     *manager.IngressManager
+	CertificateManager *manager.CertificateManager
+}
+
+// NewOnDemandIssuer creates a new issuer for requesting certificates at the first TLS handshake.
+func NewOnDemandIssuer() *OnDemandIssuer {
+	return &OnDemandIssuer{
+		logger:        log.WithName("on-demand"),
+		client:        &http.Client{Timeout: 5 * time.Second},
+		attempts:      make(map[string]time.Time),
+		ChallengeType: types.ChallengeTypeHTTP,
+		Interval:      DefaultOnDemandInterval,
+	}
+}
+
+// Request will issue a certificate for the domain in the background.
+// It will return false if the domain is not valid or an attempt was made within the interval.
+func (o *OnDemandIssuer) Request(domain string) bool {
+	domain = strings.ToLower(domain)
+	if !isValidHostname(domain) {
+		return false
+	}
+
+	if !o.acquire(domain, time.Now()) {
+		o.logger.Tracef("Skipping on-demand certificate for domain=%s, attempted within interval=%s", domain, o.Interval)
+		return false
+	}
+
+	go o.issue(domain)
+	return true
+}
+
+// acquire will record the attempt for the domain if no attempt was made within the interval.
+func (o *OnDemandIssuer) acquire(domain string, now time.Time) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if last, ok := o.attempts[domain]; ok && now.Sub(last) < o.Interval {
+		return false
+	}
+
+	if len(o.attempts) >= MaxOnDemandDomains {
+		for d, last := range o.attempts {
+			if now.Sub(last) >= o.Interval {
+				delete(o.attempts, d)
+			}
+		}
+
+		if len(o.attempts) >= MaxOnDemandDomains {
+			return false
+		}
+	}
+
+	o.attempts[domain] = now
+	return true
+}
+
+// issue will request the certificate for the domain if it is permitted.
+func (o *OnDemandIssuer) issue(domain string) {
+	if o.RequireRoute && !o.IngressManager.IsRouted(domain) {
+		o.logger.Debugf("Not requesting on-demand certificate for domain=%s, no route available", domain)
+		return
+	}
+
+	allowed, err := o.permitted(domain)
+	if err != nil {
+		o.logger.Errorf("Failed to check if on-demand certificate is permitted for domain=%s: %v", domain, err)
+		return
+	}
+
+	if !allowed {
+		o.logger.Debugf("Not requesting on-demand certificate for domain=%s, domain is not permitted", domain)
+		return
+	}
+
+	o.logger.Infof("Requesting on-demand certificate for domain=%s (challenge=%s)", domain, o.ChallengeType)
+	if err = o.CertificateManager.ChallengeCreateOnDemand([]string{domain}, o.ChallengeType); err != nil {
+		o.logger.Errorf("Failed to request on-demand certificate for domain=%s: %v", domain, err)
+	}
+}
+
+// permitted will return true if the domain is allowed by the allow-list or the ask endpoint.
+func (o *OnDemandIssuer) permitted(domain string) (bool, error) {
+	for _, allowed := range o.AllowedDomains {
+		if allowed == domain || allowed == types.WildcardOf(domain) {
+			return true, nil
+		}
+	}
+
+	if o.AskURL == "" {
+		return false, nil
+	}
+
+	endpoint, err := url.Parse(o.AskURL)
+	if err != nil {
+		return false, err
+	}
+
+	query := endpoint.Query()
+	query.Set("domain", domain)
+	endpoint.RawQuery = query.Encode()
+
+	resp, err := o.client.Get(endpoint.String())
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return false, fmt.Errorf("unexpected status code %d from ask endpoint", resp.StatusCode)
+	default:
+		return false, nil
+	}
+}
+
+// isValidHostname will return true if the server name can be used for requesting a certificate.
+func isValidHostname(domain string) bool {
+	if len(domain) == 0 || len(domain) > 253 || !strings.Contains(domain, ".") {
+		return false
+	}
+
+	for _, label := range strings.Split(domain, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOnDemandIssuer_acquire(t *testing.T) {
+	o := NewOnDemandIssuer()
+	o.Interval = time.Minute
+	now := time.Now()
+
+	if !o.acquire("www.example.com", now) {
+		t.Errorf("Expected first attempt to be allowed")
+	}
+	if o.acquire("www.example.com", now.Add(30*time.Second)) {
+		t.Errorf("Expected attempt within interval to be rate limited")
+	}
+	if !o.acquire("other.example.com", now.Add(30*time.Second)) {
+		t.Errorf("Expected attempt for another domain to be allowed")
+	}
+	if !o.acquire("www.example.com", now.Add(time.Minute)) {
+		t.Errorf("Expected attempt after interval to be allowed")
+	}
+}
+
+func TestOnDemandIssuer_acquire_limit(t *testing.T) {
+	o := NewOnDemandIssuer()
+	o.Interval = time.Minute
+	now := time.Now()
+
+	for i := 0; i < MaxOnDemandDomains; i++ {
+		o.attempts[time.Duration(i).String()] = now
+	}
+
+	if o.acquire("www.example.com", now) {
+		t.Errorf("Expected attempt to be rejected when all recent attempts are remembered")
+	}
+	if !o.acquire("www.example.com", now.Add(time.Minute)) {
+		t.Errorf("Expected attempt to be allowed once earlier attempts expired")
+	}
+	if len(o.attempts) != 1 {
+		t.Errorf("Expected expired attempts to be removed, got %d", len(o.attempts))
+	}
+}
+
+func TestOnDemandIssuer_permitted(t *testing.T) {
+	asked := make(chan string, 10)
+	ask := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		domain := r.URL.Query().Get("domain")
+		asked <- domain
+		if domain == "customer.com" && r.URL.Query().Get("token") == "secret" {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ask.Close()
+
+	o := NewOnDemandIssuer()
+	o.AllowedDomains = []string{"shop.example.com", "*.customers.example.com"}
+	o.AskURL = ask.URL + "/allowed?token=secret"
+
+	cases := map[string]bool{
+		"shop.example.com":           true,
+		"acme.customers.example.com": true,
+		"customers.example.com":      false,
+		"customer.com":               true,
+		"random.com":                 false,
+	}
+
+	for domain, expected := range cases {
+		allowed, err := o.permitted(domain)
+		if err != nil {
+			t.Fatalf("Failed to check domain=%s: %v", domain, err)
+		}
+		if allowed != expected {
+			t.Errorf("Expected domain=%s to be permitted=%v", domain, expected)
+		}
+	}
+
+	// domains on the allow-list are never asked
+	AssertEquals(t, 3, len(asked))
+}
+
+func TestIsValidHostname(t *testing.T) {
+	cases := map[string]bool{
+		"www.example.com":    true,
+		"xn--bcher-kva.com":  true,
+		"localhost":          false,
+		"*.example.com":      false,
+		"-bad.example.com":   false,
+		"www..example.com":   false,
+		"www.exa_mple.com":   false,
+		"www.example.com.":   false,
+		"192.168.1.1.nip.io": true,
+	}
+
+	for domain, expected := range cases {
+		if isValidHostname(domain) != expected {
+			t.Errorf("Expected hostname=%s to be valid=%v", domain, expected)
+		}
+	}
+}
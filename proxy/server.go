@@ -11,6 +11,7 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
 	"github.com/jorenkoyen/conter/manager"
 	"github.com/jorenkoyen/conter/manager/types"
 	"github.com/jorenkoyen/conter/version"
@@ -21,23 +22,44 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"slices"
+	"sync"
 	"time"
 
 	defaultLog "log"
 )
 
+// MaxIdleConnectionsPerEndpoint is the amount of idle upstream connections kept open for each endpoint.
+const MaxIdleConnectionsPerEndpoint = 32
+
 type Server struct {
 	logger             *logger.Logger
+	balancers          sync.Map // service -> Balancer
+	proxies            sync.Map // endpoint -> *httputil.ReverseProxy
+	connections        *ConnectionTracker
+	certificates       *CertificateCache
+	HealthChecker      *HealthChecker
+	OCSPStapler        *OCSPStapler
+	OnDemand           *OnDemandIssuer // nil when on-demand issuance is disabled
 	IngressManager     *manager.IngressManager
 	CertificateManager *manager.CertificateManager
 }
 
 func NewServer() *Server {
 	return &Server{
-		logger: log.WithName("proxy"),
+		logger:       log.WithName("proxy"),
+		connections:  new(ConnectionTracker),
+		certificates: NewCertificateCache(),
 	}
 }
 
+// InvalidateCertificates will remove all parsed certificates from the cache.
+// It should be called every time a certificate has been stored or removed.
+func (s *Server) InvalidateCertificates() {
+	s.logger.Trace("Invalidating cached TLS certificates")
+	s.certificates.Purge()
+}
+
 // ServeHTTP will route the HTTP request through to the desired proxy.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if s.IsAcmeChallenge(r) {
@@ -54,15 +76,36 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	host := ExtractDomain(r.Host)
-	route, err := s.IngressManager.Match(host)
+	route, err := s.IngressManager.Match(host, r.URL.Path)
 	if err != nil {
-		s.logger.Warningf("No route found for domain=%s, aborting... (ip=%s, agent=%s)", host, r.RemoteAddr, r.UserAgent())
+		s.logger.Warningf("No route found for domain=%s (path=%s), aborting... (ip=%s, agent=%s)", host, r.URL.Path, r.RemoteAddr, r.UserAgent())
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
+	if route.StripPrefix {
+		// remove the path prefix before handing it over to the service
+		r.URL.Path = StripPathPrefix(r.URL.Path, route.Path)
+		if r.URL.RawPath != "" {
+			r.URL.RawPath = StripPathPrefix(r.URL.RawPath, route.Path)
+		}
+	}
+
+	endpoints := route.Endpoints()
+	if s.HealthChecker != nil {
+		// skip endpoints that are failing their health checks
+		endpoints = s.HealthChecker.Filter(endpoints)
+	}
+
+	if len(endpoints) == 0 {
+		s.logger.Errorf("No healthy endpoints available for service=%s (project=%s)", route.TargetService, route.TargetProject)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
 	// proxy through request to endpoint
-	proxy, err := s.createProxyTarget(route)
+	endpoint := s.balancer(route).Pick(endpoints)
+	proxy, err := s.reverseProxy(endpoint)
 	if err != nil {
 		s.logger.Errorf("Failed to create proxy target: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -70,10 +113,23 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// proxy request
-	s.logger.Tracef("Routing through request to endpoint=%s (service=%s, method=%s, path=%s)", route.TargetEndpoint, route.TargetService, r.Method, r.URL.Path)
+	s.logger.Tracef("Routing through request to endpoint=%s (service=%s, method=%s, path=%s)", endpoint, route.TargetService, r.Method, r.URL.Path)
+	s.connections.Acquire(endpoint)
+	defer s.connections.Release(endpoint)
 	proxy.ServeHTTP(w, r)
 }
 
+// balancer will return the load balancer for the service the route is targeting.
+func (s *Server) balancer(route *types.Ingress) Balancer {
+	key := fmt.Sprintf("%s/%s/%s", route.TargetProject, route.TargetService, route.LoadBalancer)
+	if existing, ok := s.balancers.Load(key); ok {
+		return existing.(Balancer)
+	}
+
+	created, _ := s.balancers.LoadOrStore(key, NewBalancer(route.LoadBalancer, s.connections))
+	return created.(Balancer)
+}
+
 // ListenForHTTP will start listening for incoming HTTP request that require to be proxied through.
 func (s *Server) ListenForHTTP(ctx context.Context, addr string) error {
 	server := &http.Server{
@@ -103,6 +159,7 @@ func (s *Server) ListenForHTTPS(ctx context.Context, addr string) error {
 		TLSConfig: &tls.Config{
 			MinVersion:     tls.VersionTLS12,
 			GetCertificate: s.getCertificate,
+			NextProtos:     []string{"h2", "http/1.1", tlsalpn01.ACMETLS1Protocol},
 		},
 		ErrorLog: defaultLog.New(io.Discard, "", 0),
 	}
@@ -120,14 +177,57 @@ func (s *Server) ListenForHTTPS(ctx context.Context, addr string) error {
 	return server.ListenAndServeTLS("", "")
 }
 
-func (s *Server) createProxyTarget(ingress *types.Ingress) (*httputil.ReverseProxy, error) {
-	target, err := url.Parse(fmt.Sprintf("http://%s", ingress.TargetEndpoint))
+// reverseProxy will return the long-lived reverse proxy for the endpoint.
+// The proxy (and its transport) is created on first use so upstream connections can be reused between requests.
+func (s *Server) reverseProxy(endpoint string) (*httputil.ReverseProxy, error) {
+	if existing, ok := s.proxies.Load(endpoint); ok {
+		return existing.(*httputil.ReverseProxy), nil
+	}
+
+	proxy, err := s.newReverseProxy(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	created, _ := s.proxies.LoadOrStore(endpoint, proxy)
+	return created.(*httputil.ReverseProxy), nil
+}
+
+// PruneProxies will remove the reverse proxies of endpoints that are no longer routed and close their idle connections.
+// It should be called every time the routes have been rebuilt.
+func (s *Server) PruneProxies() {
+	routed := make(map[string]struct{})
+	for _, route := range s.IngressManager.GetAllRoutes() {
+		for _, endpoint := range route.Endpoints() {
+			routed[endpoint] = struct{}{}
+		}
+	}
+
+	s.proxies.Range(func(key, value any) bool {
+		if _, ok := routed[key.(string)]; ok {
+			return true
+		}
+
+		s.logger.Tracef("Removing reverse proxy for endpoint=%s, no longer routed", key)
+		s.proxies.Delete(key)
+		value.(*httputil.ReverseProxy).Transport.(*http.Transport).CloseIdleConnections()
+		return true
+	})
+}
+
+// newReverseProxy will create a new reverse proxy with a dedicated transport for the endpoint.
+func (s *Server) newReverseProxy(endpoint string) (*httputil.ReverseProxy, error) {
+	target, err := url.Parse(fmt.Sprintf("http://%s", endpoint))
 	if err != nil {
 		return nil, err
 	}
 
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = MaxIdleConnectionsPerEndpoint
+
 	// Create a reverse proxy pointing to the target URL
 	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = transport
 
 	proxy.ModifyResponse = func(r *http.Response) error {
 		// overwrite Server header
@@ -136,7 +236,7 @@ func (s *Server) createProxyTarget(ingress *types.Ingress) (*httputil.ReversePro
 	}
 
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		s.logger.Errorf("Failed to route request to service=%s: %v", ingress.TargetService, err)
+		s.logger.Errorf("Failed to route request for host=%s to endpoint=%s: %v", r.Host, endpoint, err)
 		w.WriteHeader(http.StatusServiceUnavailable)
 	}
 
@@ -149,6 +249,20 @@ func (s *Server) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, e
 		return nil, errors.New("no mappings available without a domain")
 	}
 
+	if slices.Contains(hello.SupportedProtos, tlsalpn01.ACMETLS1Protocol) {
+		// ACME server is validating the TLS-ALPN-01 challenge
+		s.logger.Debugf("Returning TLS-ALPN-01 challenge certificate for domain=%s", hello.ServerName)
+		return s.CertificateManager.ChallengeCertificate(hello.ServerName)
+	}
+
+	if cached, ok := s.certificates.Get(hello.ServerName); ok {
+		if s.OnDemand != nil && s.certificates.IsSelfSigned(hello.ServerName) {
+			// retry issuing the certificate, the interval of the on-demand issuer limits the attempts
+			s.OnDemand.Request(hello.ServerName)
+		}
+		return s.staple(cached), nil
+	}
+
 	cert := s.CertificateManager.Get(hello.ServerName)
 	if cert == nil {
 		// No certificate found, generate a self-signed certificate
@@ -158,11 +272,31 @@ func (s *Server) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, e
 		}
 
 		s.logger.Debugf("No certificate available, generated temporary self-signed certificate for domain=%s", hello.ServerName)
+		s.certificates.Set(hello.ServerName, selfSignedCert, true)
+		if s.OnDemand != nil {
+			// the self-signed certificate is served until the certificate has been issued
+			s.OnDemand.Request(hello.ServerName)
+		}
 		return selfSignedCert, nil
 	}
 
+	pair, err := cert.X509KeyPair()
+	if err != nil {
+		return nil, err
+	}
+
 	s.logger.Tracef("Returning certificcate for domain=%s", hello.ServerName)
-	return cert.X509KeyPair()
+	s.certificates.Set(hello.ServerName, pair, false)
+	return s.staple(pair), nil
+}
+
+// staple will attach the OCSP response to the certificate if OCSP stapling is enabled.
+func (s *Server) staple(certificate *tls.Certificate) *tls.Certificate {
+	if s.OCSPStapler == nil {
+		return certificate
+	}
+
+	return s.OCSPStapler.Staple(certificate)
 }
 
 // generateSelfSignedCertificate generates a self-signed TLS certificate for the given domain.
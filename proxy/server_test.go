@@ -0,0 +1,270 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
+	"github.com/jorenkoyen/conter/manager"
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
+)
+
+// createBenchmarkServer will create a proxy server with a single route towards a local upstream.
+func createBenchmarkServer(b *testing.B) (*Server, string) {
+	b.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	b.Cleanup(upstream.Close)
+
+	database := db.NewClient(b.TempDir())
+	b.Cleanup(func() { _ = database.Close() })
+
+	endpoint := strings.TrimPrefix(upstream.URL, "http://")
+	route := &types.Ingress{
+		Domains:         []string{"www.example.com"},
+		TargetEndpoints: []string{endpoint},
+		TargetService:   "web",
+		TargetProject:   "benchmark",
+	}
+	if err := database.SaveIngressRoute(route); err != nil {
+		b.Fatalf("Failed to save route: %v", err)
+	}
+
+	ingressManager := manager.NewIngressManager()
+	ingressManager.Database = database
+	database.OnRoutesChanged(ingressManager.Rebuild)
+
+	s := NewServer()
+	s.IngressManager = ingressManager
+	return s, endpoint
+}
+
+func BenchmarkServer_ServeHTTP(b *testing.B) {
+	s, endpoint := createBenchmarkServer(b)
+
+	b.Run("uncached", func(b *testing.B) {
+		// a new reverse proxy (and transport) for every request as it was done before caching
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			proxy, err := s.newReverseProxy(endpoint)
+			if err != nil {
+				b.Fatalf("Failed to create proxy: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "https://www.example.com/", nil)
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, req)
+			proxy.Transport.(*http.Transport).CloseIdleConnections()
+			if rec.Code != http.StatusOK {
+				b.Fatalf("Unexpected status code %d", rec.Code)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			req := httptest.NewRequest(http.MethodGet, "https://www.example.com/", nil)
+			req.TLS = new(tls.ConnectionState)
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				b.Fatalf("Unexpected status code %d", rec.Code)
+			}
+		}
+	})
+}
+
+func TestServer_PruneProxies(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+
+	ingressManager := manager.NewIngressManager()
+	ingressManager.Database = database
+	database.OnRoutesChanged(ingressManager.Rebuild)
+
+	s := NewServer()
+	s.IngressManager = ingressManager
+	database.OnRoutesChanged(s.PruneProxies)
+
+	route := &types.Ingress{Domains: []string{"www.example.com"}, TargetEndpoints: []string{"10.0.0.1:80"}, TargetService: "web", TargetProject: "default"}
+	if err := database.SaveIngressRoute(route); err != nil {
+		t.Fatalf("Failed to save route: %v", err)
+	}
+
+	for _, endpoint := range []string{"10.0.0.1:80", "10.0.0.2:80"} {
+		if _, err := s.reverseProxy(endpoint); err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+	}
+
+	// the endpoint of the route is kept, the unrouted endpoint is removed
+	s.PruneProxies()
+	_, routed := s.proxies.Load("10.0.0.1:80")
+	_, unrouted := s.proxies.Load("10.0.0.2:80")
+	AssertEquals(t, true, routed)
+	AssertEquals(t, false, unrouted)
+
+	// the proxy is removed together with the route
+	if err := database.RemoveIngressRoute(types.RouteKey("www.example.com", "/")); err != nil {
+		t.Fatalf("Failed to remove route: %v", err)
+	}
+	_, routed = s.proxies.Load("10.0.0.1:80")
+	AssertEquals(t, false, routed)
+}
+
+func TestServer_getCertificate_tlsALPN(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+
+	s := NewServer()
+	s.CertificateManager = manager.NewCertificateManger(database, "", nil, false)
+
+	hello := &tls.ClientHelloInfo{ServerName: "www.example.com", SupportedProtos: []string{tlsalpn01.ACMETLS1Protocol}}
+	if _, err := s.getCertificate(hello); err == nil {
+		t.Errorf("Expected an error without a pending challenge")
+	}
+
+	if err := database.SetAcmeChallenge("www.example.com", "token", "auth"); err != nil {
+		t.Fatalf("Failed to save challenge: %v", err)
+	}
+
+	cert, err := s.getCertificate(hello)
+	if err != nil {
+		t.Fatalf("Expected challenge certificate: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse challenge certificate: %v", err)
+	}
+
+	// id-pe-acmeIdentifier (RFC 8737)
+	oid := asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+	if !slices.ContainsFunc(leaf.Extensions, func(ext pkix.Extension) bool { return ext.Id.Equal(oid) }) {
+		t.Errorf("Expected challenge certificate to contain the ACME identifier extension")
+	}
+}
+
+func TestServer_getCertificate_cache(t *testing.T) {
+	database := db.NewClient(t.TempDir())
+	t.Cleanup(func() { _ = database.Close() })
+
+	s := NewServer()
+	s.CertificateManager = manager.NewCertificateManger(database, "", nil, false)
+	database.OnCertificatesChanged(s.InvalidateCertificates)
+
+	hello := &tls.ClientHelloInfo{ServerName: "www.example.com"}
+
+	{
+		// self-signed fallback is reused
+		first, err := s.getCertificate(hello)
+		if err != nil {
+			t.Fatalf("Expected self-signed certificate: %v", err)
+		}
+
+		second, err := s.getCertificate(hello)
+		if err != nil {
+			t.Fatalf("Expected self-signed certificate: %v", err)
+		}
+
+		if first != second {
+			t.Errorf("Expected self-signed certificate to be cached")
+		}
+	}
+
+	{
+		// storing a certificate invalidates the cache
+		cert, err := s.CertificateManager.IssueInternal([]string{"www.example.com"}, types.KeyOptions{})
+		if err != nil {
+			t.Fatalf("Failed to issue certificate: %v", err)
+		}
+
+		served, err := s.getCertificate(hello)
+		if err != nil {
+			t.Fatalf("Expected certificate: %v", err)
+		}
+
+		expected, _ := cert.Parse()
+		AssertEquals(t, expected.SerialNumber.String(), served.Leaf.SerialNumber.String())
+
+		cached, err := s.getCertificate(hello)
+		if err != nil {
+			t.Fatalf("Expected certificate: %v", err)
+		}
+
+		if served != cached {
+			t.Errorf("Expected certificate to be cached")
+		}
+	}
+
+	{
+		// removing a certificate invalidates the cache
+		if err := database.RemoveCertificate(s.CertificateManager.Get("www.example.com")); err != nil {
+			t.Fatalf("Failed to remove certificate: %v", err)
+		}
+
+		if s.certificates.Len() != 0 {
+			t.Errorf("Expected cache to be empty after removing a certificate")
+		}
+	}
+}
+
+func BenchmarkServer_getCertificate(b *testing.B) {
+	database := db.NewClient(b.TempDir())
+	b.Cleanup(func() { _ = database.Close() })
+
+	s := NewServer()
+	s.CertificateManager = manager.NewCertificateManger(database, "", nil, false)
+	if _, err := s.CertificateManager.IssueInternal([]string{"www.example.com"}, types.KeyOptions{}); err != nil {
+		b.Fatalf("Failed to issue certificate: %v", err)
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		// lookup and parse the stored certificate on every handshake as it was done before caching
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := s.CertificateManager.Get("www.example.com").X509KeyPair(); err != nil {
+				b.Fatalf("Failed to parse certificate: %v", err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		hello := &tls.ClientHelloInfo{ServerName: "www.example.com"}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := s.getCertificate(hello); err != nil {
+				b.Fatalf("Failed to get certificate: %v", err)
+			}
+		}
+	})
+
+	b.Run("self-signed/uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := generateSelfSignedCertificate("unknown.example.com"); err != nil {
+				b.Fatalf("Failed to generate certificate: %v", err)
+			}
+		}
+	})
+
+	b.Run("self-signed/cached", func(b *testing.B) {
+		hello := &tls.ClientHelloInfo{ServerName: "unknown.example.com"}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := s.getCertificate(hello); err != nil {
+				b.Fatalf("Failed to get certificate: %v", err)
+			}
+		}
+	})
+}
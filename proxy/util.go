@@ -1,6 +1,10 @@
 package proxy
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/jorenkoyen/conter/manager/types"
+)
 
 // RewriteToHTTPS will rewrite any incoming URL to the HTTPS scheme.
 func RewriteToHTTPS(host, uri string) string {
@@ -17,3 +21,19 @@ func ExtractDomain(host string) string {
 		return host
 	}
 }
+
+// StripPathPrefix will remove the path prefix from the request path.
+// The resulting path will always start with a slash.
+func StripPathPrefix(path string, prefix string) string {
+	prefix = types.NormalizePath(prefix)
+	if prefix == "/" {
+		return path
+	}
+
+	stripped := strings.TrimPrefix(path, prefix)
+	if !strings.HasPrefix(stripped, "/") {
+		stripped = "/" + stripped
+	}
+
+	return stripped
+}
@@ -19,3 +19,12 @@ func TestRewriteToHTTPS(t *testing.T) {
 	// with paths
 	AssertEquals(t, "https://www.example.com/pages/path/about.html", RewriteToHTTPS("www.example.com", "/pages/path/about.html"))
 }
+
+func TestStripPathPrefix(t *testing.T) {
+	// root prefix
+	AssertEquals(t, "/users", StripPathPrefix("/users", "/"))
+	// exact prefix
+	AssertEquals(t, "/", StripPathPrefix("/api", "/api"))
+	// nested path
+	AssertEquals(t, "/users/1", StripPathPrefix("/api/users/1", "/api/"))
+}
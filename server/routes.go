@@ -1,9 +1,12 @@
 package server
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"github.com/jorenkoyen/conter/manager"
+	"github.com/jorenkoyen/conter/manager/db"
+	"github.com/jorenkoyen/conter/manager/types"
 	"github.com/karlseguin/jsonwriter"
 	"net/http"
 	"time"
@@ -38,12 +41,25 @@ func (s *Server) HandleProjectApply(w http.ResponseWriter, r *http.Request) erro
 				writer.ArrayObject(func() {
 					writer.KeyString("name", service.Name)
 					writer.KeyString("hash", service.Hash)
-					writer.KeyString("status", manager.StatusRunning) // always running when applied
+					writer.KeyInt("replicas", service.ReplicaCount())
+					if service.HealthCheck != nil {
+						writer.KeyString("status", manager.StatusStarting) // healthy once the first checks succeed
+						writeContainerHealthCheck(writer, service.HealthCheck)
+					} else {
+						writer.KeyString("status", manager.StatusRunning) // always running when applied
+					}
 
 					if service.IsExposed() {
 						writer.Object("ingress", func() {
-							writer.KeyString("internal", service.Ingress.TargetEndpoint)
 							writer.KeyString("challenge", string(service.Ingress.ChallengeType))
+							writer.KeyString("load_balancer", string(service.Ingress.LoadBalancer))
+							writer.Array("endpoints", func() {
+								for _, endpoint := range service.Ingress.Endpoints() {
+									writer.Value(endpoint)
+								}
+							})
+							writer.KeyString("path", service.Ingress.PathPrefix())
+							writer.KeyValue("strip_prefix", service.Ingress.StripPrefix)
 							writer.Array("domains", func() {
 								for _, domain := range service.Ingress.Domains {
 									writer.Value(domain)
@@ -105,12 +121,63 @@ func (s *Server) HandleProjectRetrieve(w http.ResponseWriter, r *http.Request) e
 				writer.ArrayObject(func() {
 					writer.KeyString("name", service.Name)
 					writer.KeyString("hash", service.Hash)
+					writer.KeyInt("replicas", service.ReplicaCount())
 					writer.KeyString("status", status.GetState(service.Name))
+					if service.HealthCheck != nil {
+						writeContainerHealthCheck(writer, service.HealthCheck)
+					}
+
+					now := time.Now()
+					writer.Array("containers", func() {
+						for _, container := range status.GetContainers(service.Name) {
+							writer.ArrayObject(func() {
+								writer.KeyString("id", container.ID)
+								writer.KeyString("name", container.Name)
+								writer.KeyString("state", container.State)
+								if container.Health != "" {
+									writer.KeyString("health", container.Health)
+								}
+								writer.KeyString("image_digest", container.ImageDigest)
+								if !container.StartedAt.IsZero() {
+									writer.KeyString("started_at", container.StartedAt.Format(time.RFC3339))
+								}
+								writer.KeyInt("uptime", int(container.Uptime(now).Seconds()))
+								writer.KeyInt("restart_count", container.RestartCount)
+								writer.KeyInt("exit_code", container.ExitCode)
+								writer.KeyValue("oom_killed", container.OOMKilled)
+							})
+						}
+					})
 
 					if service.IsExposed() {
 						writer.Object("ingress", func() {
-							writer.KeyString("internal", service.Ingress.TargetEndpoint)
 							writer.KeyString("challenge", string(service.Ingress.ChallengeType))
+							writer.KeyString("load_balancer", string(service.Ingress.LoadBalancer))
+							writer.Array("endpoints", func() {
+								for _, endpoint := range service.Ingress.Endpoints() {
+									writer.Value(endpoint)
+								}
+							})
+
+							if service.Ingress.HealthCheck != nil && s.HealthChecker != nil {
+								writer.Array("health", func() {
+									for _, endpoint := range service.Ingress.Endpoints() {
+										health := s.HealthChecker.Get(endpoint)
+										writer.ArrayObject(func() {
+											writer.KeyString("endpoint", endpoint)
+											writer.KeyString("status", health.Status())
+											if health.Checked {
+												writer.KeyString("last_check", health.LastCheck.Format(time.RFC3339))
+											}
+											if health.LastError != "" {
+												writer.KeyString("error", health.LastError)
+											}
+										})
+									}
+								})
+							}
+							writer.KeyString("path", service.Ingress.PathPrefix())
+							writer.KeyValue("strip_prefix", service.Ingress.StripPrefix)
 							writer.Array("domains", func() {
 								for _, domain := range service.Ingress.Domains {
 									writer.Value(domain)
@@ -175,21 +242,16 @@ func (s *Server) HandleCertificatesRetrieve(w http.ResponseWriter, r *http.Reque
 					}
 				})
 
+				writer.KeyValue("reuse_key", certificate.ReuseKey)
+
 				info, err := certificate.Parse()
 				if err != nil {
 					// skip information
 					return
 				}
 
-				writer.Object("meta", func() {
-					writer.KeyString("subject", info.Subject.CommonName)
-					writer.KeyString("issuer", info.Issuer.CommonName)
-					writer.KeyString("since", info.NotBefore.Format(time.RFC3339))
-					writer.KeyString("expiry", info.NotAfter.Format(time.RFC3339))
-					writer.KeyString("serial", info.SerialNumber.String())
-					writer.KeyString("signature_algorithm", info.SignatureAlgorithm.String())
-					writer.KeyString("public_algorithm", info.PublicKeyAlgorithm.String())
-				})
+				writer.KeyString("key_type", string(types.KeyTypeOf(info.PublicKey)))
+				writeCertificateMeta(writer, info)
 			})
 		}
 	})
@@ -217,15 +279,81 @@ func (s *Server) HandleCertificateRetrieveData(w http.ResponseWriter, r *http.Re
 			}
 		})
 
+		writer.KeyValue("reuse_key", cert.ReuseKey)
+
+		if info, err := cert.Parse(); err == nil {
+			writer.KeyString("key_type", string(types.KeyTypeOf(info.PublicKey)))
+			writeCertificateMeta(writer, info)
+		}
+	})
+	return nil
+}
+
+func (s *Server) HandleCertificateImport(w http.ResponseWriter, r *http.Request) error {
+	if !IsJson(r) {
+		return errors.New("invalid content type")
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	opts := new(manager.ImportCertificateOptions)
+	if err := decoder.Decode(opts); err != nil {
+		return err
+	}
+
+	cert, err := s.CertificateManager.Import(*opts)
+	if err != nil {
+		s.logger.Warningf("Failed to import certificate: %v", err)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	writer := jsonwriter.New(w)
+	writer.RootObject(func() {
+		writer.KeyString("id", cert.ID)
+		writer.KeyString("challenge", string(cert.ChallengeType))
+		writer.Array("domains", func() {
+			for _, d := range cert.Domains {
+				writer.Value(d)
+			}
+		})
+
+		writer.KeyValue("reuse_key", cert.ReuseKey)
+
 		if info, err := cert.Parse(); err == nil {
-			writer.Object("meta", func() {
-				writer.KeyString("subject", info.Subject.CommonName)
-				writer.KeyString("issuer", info.Issuer.CommonName)
-				writer.KeyString("since", info.NotBefore.Format(time.RFC3339))
-				writer.KeyString("expiry", info.NotAfter.Format(time.RFC3339))
-				writer.KeyString("serial", info.SerialNumber.String())
-				writer.KeyString("signature_algorithm", info.SignatureAlgorithm.String())
-				writer.KeyString("public_algorithm", info.PublicKeyAlgorithm.String())
+			writer.KeyString("key_type", string(types.KeyTypeOf(info.PublicKey)))
+			writeCertificateMeta(writer, info)
+		}
+	})
+	return nil
+}
+
+func (s *Server) HandleCertificateOrders(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	writer := jsonwriter.New(w)
+	writer.RootArray(func() {
+		for _, order := range s.CertificateManager.GetOrders() {
+			writer.ArrayObject(func() {
+				writer.Array("domains", func() {
+					for _, d := range order.Domains {
+						writer.Value(d)
+					}
+				})
+				writer.KeyString("challenge", string(order.ChallengeType))
+				writer.KeyString("state", string(order.State))
+				writer.KeyValue("attempts", order.Attempts)
+				writer.KeyValue("rate_limited", order.RateLimited)
+				writer.KeyString("created_at", order.CreatedAt.Format(time.RFC3339))
+				writer.KeyString("updated_at", order.UpdatedAt.Format(time.RFC3339))
+				if order.LastError != "" {
+					writer.KeyString("error", order.LastError)
+				}
+				if !order.NextRetry.IsZero() {
+					writer.KeyString("next_retry", order.NextRetry.Format(time.RFC3339))
+				}
 			})
 		}
 	})
@@ -240,7 +368,7 @@ func (s *Server) HandleCertificateRenew(w http.ResponseWriter, r *http.Request)
 		return errors.New("not found")
 	}
 
-	err := s.CertificateManager.ChallengeCreate([]string{domain}, cert.ChallengeType)
+	err := s.CertificateManager.ChallengeCreate([]string{domain}, cert.ChallengeType, cert.KeyOptions())
 	if err != nil {
 		return err
 	}
@@ -249,18 +377,202 @@ func (s *Server) HandleCertificateRenew(w http.ResponseWriter, r *http.Request)
 	return nil
 }
 
+// ExportPasswordHeader is the request header holding the password protecting an exported PKCS#12 archive.
+// It is not accepted as query parameter, so it does not end up in access logs or the shell history.
+const ExportPasswordHeader = "X-Export-Password"
+
+func (s *Server) HandleCertificateExport(w http.ResponseWriter, r *http.Request) error {
+	domain := r.PathValue("domain")
+	format := manager.ExportFormat(r.URL.Query().Get("format"))
+	if format == "" {
+		format = manager.ExportFormatPEM
+	}
+
+	content, err := s.CertificateManager.Export(domain, format, r.Header.Get(ExportPasswordHeader))
+	if errors.Is(err, db.ErrItemNotFound) {
+		return errors.New("not found")
+	} else if err != nil {
+		return err
+	}
+
+	if format == manager.ExportFormatPKCS12 {
+		w.Header().Set("Content-Type", "application/x-pkcs12")
+	} else {
+		w.Header().Set("Content-Type", "application/x-pem-file")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(content)
+	return nil
+}
+
+func (s *Server) HandleCertificateAuthority(w http.ResponseWriter, r *http.Request) error {
+	content, err := s.CertificateManager.CertificateAuthority()
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(content)
+	return nil
+}
+
+func (s *Server) HandleCertificateRevoke(w http.ResponseWriter, r *http.Request) error {
+	domain := r.PathValue("domain")
+	err := s.CertificateManager.Revoke(domain)
+	if errors.Is(err, db.ErrItemNotFound) {
+		s.logger.Warningf("No certificate found for domain=%s when trying to revoke", domain)
+		return errors.New("not found")
+	} else if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) HandleAcmeAccountRetrieve(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.CertificateManager.Account(r.URL.Query().Get("issuer"))
+	if err != nil {
+		return err
+	}
+
+	writeAcmeAccount(w, account)
+	return nil
+}
+
+func (s *Server) HandleAcmeAccountUpdate(w http.ResponseWriter, r *http.Request) error {
+	if !IsJson(r) {
+		return errors.New("invalid content type")
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	opts := new(manager.UpdateAccountOptions)
+	if err := decoder.Decode(opts); err != nil {
+		return err
+	}
+
+	issuer := r.URL.Query().Get("issuer")
+	account, err := s.CertificateManager.UpdateAccount(issuer, *opts)
+	if err != nil {
+		s.logger.Warningf("Failed to update ACME account of issuer=%s: %v", issuer, err)
+		return err
+	}
+
+	writeAcmeAccount(w, account)
+	return nil
+}
+
+func writeAcmeAccount(w http.ResponseWriter, account *manager.AcmeAccount) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	writer := jsonwriter.New(w)
+	writer.RootObject(func() {
+		writer.KeyString("issuer", account.Issuer)
+		writer.KeyString("directory", account.Directory)
+		writer.KeyString("email", account.Email)
+		writer.KeyString("uri", account.URI)
+		writer.KeyString("status", account.Status)
+		writer.Array("contact", func() {
+			for _, contact := range account.Contact {
+				writer.Value(contact)
+			}
+		})
+		writer.KeyString("key_thumbprint", account.KeyThumbprint)
+	})
+}
+
 func (s *Server) HandleSystemTask(w http.ResponseWriter, r *http.Request) error {
 	task := r.PathValue("task")
 	if task == "" {
 		return errors.New("missing task parameter value")
 	}
 
-	if task == "batch_certificates" {
-		s.CertificateManager.Batch()
-	} else {
+	err := s.Scheduler.Run(task)
+	if errors.Is(err, manager.ErrJobNotFound) {
 		return errors.New("unable to handle task, unknown to system")
+	} else if err != nil {
+		return err
 	}
 
 	w.WriteHeader(http.StatusOK)
 	return nil
 }
+
+func (s *Server) HandleSystemJobs(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	writer := jsonwriter.New(w)
+	writer.RootArray(func() {
+		for _, job := range s.Scheduler.Jobs() {
+			writer.ArrayObject(func() {
+				writer.KeyString("name", job.Name)
+				writer.KeyString("interval", job.Options.Interval.String())
+				writer.KeyString("jitter", job.Options.Jitter.String())
+				writer.KeyString("result", job.Result())
+				writer.KeyValue("runs", job.Runs)
+				if !job.LastRun.IsZero() {
+					writer.KeyString("last_run", job.LastRun.Format(time.RFC3339))
+					writer.KeyString("last_duration", job.LastDuration.String())
+				}
+				if !job.NextRun.IsZero() {
+					writer.KeyString("next_run", job.NextRun.Format(time.RFC3339))
+				}
+				if job.LastError != "" {
+					writer.KeyString("error", job.LastError)
+				}
+			})
+		}
+	})
+	return nil
+}
+
+// writeContainerHealthCheck will write the health check configured for the service as the 'healthcheck' object.
+func writeContainerHealthCheck(writer *jsonwriter.Writer, check *types.ContainerHealthCheck) {
+	writer.Object("healthcheck", func() {
+		if len(check.Command) > 0 {
+			writer.Array("command", func() {
+				for _, arg := range check.Command {
+					writer.Value(arg)
+				}
+			})
+		} else {
+			writer.KeyString("path", types.NormalizePath(check.Path))
+		}
+		writer.KeyInt("interval", int(check.IntervalDuration().Seconds()))
+		writer.KeyInt("timeout", int(check.TimeoutDuration().Seconds()))
+		writer.KeyInt("retries", check.RetryCount())
+		writer.KeyInt("start_period", int(check.StartPeriodDuration().Seconds()))
+	})
+}
+
+// writeCertificateMeta will write the information of the parsed certificate as the 'meta' object.
+func writeCertificateMeta(writer *jsonwriter.Writer, info *x509.Certificate) {
+	writer.Object("meta", func() {
+		writer.KeyString("subject", info.Subject.CommonName)
+		writer.KeyString("issuer", info.Issuer.CommonName)
+		writer.KeyString("since", info.NotBefore.Format(time.RFC3339))
+		writer.KeyString("expiry", info.NotAfter.Format(time.RFC3339))
+		writer.KeyString("serial", info.SerialNumber.String())
+		writer.KeyString("signature_algorithm", info.SignatureAlgorithm.String())
+		writer.KeyString("public_algorithm", info.PublicKeyAlgorithm.String())
+	})
+}
+
+func (s *Server) HandleSystemRotateKey(w http.ResponseWriter, r *http.Request) error {
+	id, err := s.CertificateManager.RotateEncryptionKey()
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	writer := jsonwriter.New(w)
+	writer.RootObject(func() {
+		writer.KeyString("key_id", id)
+	})
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/jorenkoyen/conter/manager"
+	"github.com/jorenkoyen/conter/proxy"
 	"github.com/jorenkoyen/go-logger"
 	"github.com/jorenkoyen/go-logger/log"
 )
@@ -16,6 +17,8 @@ type Server struct {
 
 	ContainerManager   *manager.Container
 	CertificateManager *manager.CertificateManager
+	HealthChecker      *proxy.HealthChecker
+	Scheduler          *manager.Scheduler
 }
 
 // NewServer will create a new management HTTP server.
@@ -38,10 +41,21 @@ func NewServer(addr string) *Server {
 
 	// -- certificates
 	mux.Handle("GET /api/certificates", s.HandleCertificatesRetrieve)
+	mux.Handle("POST /api/certificates", s.HandleCertificateImport)
+	mux.Handle("GET /api/certificates/orders", s.HandleCertificateOrders)
+	mux.Handle("GET /api/certificates/ca", s.HandleCertificateAuthority)
 	mux.Handle("GET /api/certificates/{domain}", s.HandleCertificateRetrieveData)
+	mux.Handle("DELETE /api/certificates/{domain}", s.HandleCertificateRevoke)
+	mux.Handle("GET /api/certificates/{domain}/export", s.HandleCertificateExport)
 	mux.Handle("POST /api/certificates/{domain}/renew", s.HandleCertificateRenew)
 
+	// -- acme
+	mux.Handle("GET /api/acme/account", s.HandleAcmeAccountRetrieve)
+	mux.Handle("PUT /api/acme/account", s.HandleAcmeAccountUpdate)
+
 	// -- system
+	mux.Handle("GET /api/system/jobs", s.HandleSystemJobs)
+	mux.Handle("POST /api/system/rotate-key", s.HandleSystemRotateKey)
 	mux.Handle("GET /api/system/{task}", s.HandleSystemTask)
 
 	return s
@@ -0,0 +1,20 @@
+#!/usr/bin/env bash
+# DNS-01 hook for the LEGO 'exec' provider that publishes the TXT records on the pebble challenge test server.
+#
+# usage: challtestsrv.sh (present|cleanup) <fqdn> <value>
+
+set -o errexit -o nounset -o pipefail
+
+MANAGEMENT_URL="${CHALLTESTSRV_URL:-http://127.0.0.1:8055}"
+
+case "$1" in
+  present)
+    curl -sSf -X POST -d "{\"host\":\"$2\",\"value\":\"$3\"}" "$MANAGEMENT_URL/set-txt"
+    ;;
+  cleanup)
+    curl -sSf -X POST -d "{\"host\":\"$2\"}" "$MANAGEMENT_URL/clear-txt"
+    ;;
+  *)
+    # timeout is not implemented, use the defaults
+    ;;
+esac